@@ -32,6 +32,11 @@ func (h *HeroesEndPoint) Path() string {
 	return heroApiVersion + "/heroes"
 }
 
+// ResourceGroup returns the API documentation group of the endpoint
+func (h *HeroesEndPoint) ResourceGroup() string {
+	return "System Administrator"
+}
+
 /*
 RestEntries provide REST methods configuration
 Each entry specifies the following attributes:
@@ -40,6 +45,7 @@ Each entry specifies the following attributes:
   - Path: The method relative path (from the Endpoint base path)										[Mandatory]
   - Skip: Flag to indicate if the API KEY or TOKEN validations should be skipped						[Optional]
   - Role: Integer value (usually enum) to enable this entry only for token with the specified role	[Optional]
+  - Summary, Request, Response: API documentation attributes (OpenAPI)								[Optional]
 */
 func (h *HeroesEndPoint) RestEntries() (restEntries []RestEntry) {
 	restEntries = []RestEntry{
@@ -50,7 +56,7 @@ func (h *HeroesEndPoint) RestEntries() (restEntries []RestEntry) {
 		{Method: http.MethodPatch, Handler: h.handler, Path: "/", Role: Roles.SUPPORT + Roles.SALES},
 
 		{Method: http.MethodDelete, Handler: h.handler, Path: "/:id", Role: Roles.MANAGEMENT},
-		{Method: http.MethodGet, Handler: h.handler, Path: "/:id", Role: Roles.FINANCE},
+		{Method: http.MethodGet, Handler: h.handler, Path: "/:id", Role: Roles.FINANCE, Summary: "Get hero by ID", Response: ActionResponse{}},
 
 		{Method: http.MethodGet, Handler: h.handler, Path: ""},
 		{Method: http.MethodGet, Handler: h.handler, Path: "/"},
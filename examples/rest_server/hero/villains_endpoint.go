@@ -49,6 +49,8 @@ func (h *VillainsEndPoint) RestEntries() (restEntries []RestEntry) {
 		{Method: http.MethodGet, Handler: h.handler, Path: "/logo/:id"},
 
 		{Method: http.MethodGet, Handler: h.handler, Path: "/export/:format"},
+
+		{Method: http.MethodPost, Handler: Handle(h.rename), Path: "/:id/rename", Request: RenameVillainRequest{}, Response: ActionResponse{}},
 	}
 
 	// Sort entries for best match
@@ -69,4 +71,15 @@ func (h *VillainsEndPoint) handler(c *gin.Context) {
 	c.JSON(http.StatusOK, NewActionResponse(path, td.SubjectId))
 }
 
+// RenameVillainRequest is the typed request of the rename action
+type RenameVillainRequest struct {
+	Id   string `uri:"id"`
+	Name string `json:"name" binding:"required"`
+}
+
+// rename is a typed handler: the request is bound and validated, the response is encoded as JSON
+func (h *VillainsEndPoint) rename(c *gin.Context, req RenameVillainRequest) (*ActionResponse, error) {
+	return NewActionResponse(req.Id, req.Name), nil
+}
+
 // endregion
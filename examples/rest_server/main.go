@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"github.com/go-yaaf/yaaf-common-net/examples/rest_server/hero"
 	"github.com/go-yaaf/yaaf-common-net/examples/rest_server/sample"
 	"github.com/go-yaaf/yaaf-common-net/web"
 	"github.com/go-yaaf/yaaf-common/logger"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 var secret = "put your secret string. It must be at least 32 characters long"
@@ -39,17 +43,19 @@ func main() {
 	// Add static documentation endpoint
 	restServer.AddStaticEndpoint("/doc", "./doc")
 
+	// Serve OpenAPI document and Swagger UI
+	restServer.WithOpenAPI("/api-docs", web.OpenAPIInfo{Title: "REST Server Example"})
+
 	port := 8080
 	logger.Info("Starting REST server, listening on port: %d", port)
 
-	// Start REST server for prometheus metrics endpoint
-	go func() {
-		if err := restServer.Start(port); err != nil {
-			logger.Warn("error starting REST server: %s", err.Error())
-		} else {
-			logger.Info("Closing the REST server...")
-		}
-	}()
+	// Stop the server gracefully on SIGINT / SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	<-make(chan struct{})
+	if err := restServer.StartWithContext(ctx, port); err != nil {
+		logger.Warn("error running REST server: %s", err.Error())
+	} else {
+		logger.Info("Closing the REST server...")
+	}
 }
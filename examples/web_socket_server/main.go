@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/go-yaaf/yaaf-common-net/web"
@@ -49,19 +53,19 @@ func main() {
 	port := 8080
 	logger.Info("Starting REST server, listening on port: %d", port)
 
-	// Start Web server
-	go func() {
-		if err := webServer.Start(port); err != nil {
-			logger.Warn("error starting REST server: %s", err.Error())
-		} else {
-			logger.Info("Closing the REST server...")
-		}
-	}()
+	// Stop the server gracefully on SIGINT / SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	registry := webServer.WebSocketRegistry("airplanes")
 	go startPoller(registry)
 
-	<-make(chan struct{})
+	// Start Web server
+	if err := webServer.StartWithContext(ctx, port); err != nil {
+		logger.Warn("error running REST server: %s", err.Error())
+	} else {
+		logger.Info("Closing the REST server...")
+	}
 }
 
 // start polling open-sky status every 5 seconds
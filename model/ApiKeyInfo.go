@@ -0,0 +1,11 @@
+package model
+
+// ApiKeyInfo model represents the application info encrypted with the API key
+// @Data
+type ApiKeyInfo struct {
+	KeyId     string   `json:"kid,omitempty"`    // Unique API key ID (empty for legacy keys)
+	AppName   string   `json:"app"`              // Application name
+	Scopes    []string `json:"scopes,omitempty"` // Granted scopes, e.g. heroes:read (empty for no scopes)
+	IssuedAt  int64    `json:"iat,omitempty"`    // Issue time [Epoch milliseconds Timestamp]
+	ExpiresAt int64    `json:"exp,omitempty"`    // Expiration time [Epoch milliseconds Timestamp] (0 for never)
+}
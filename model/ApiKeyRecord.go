@@ -0,0 +1,21 @@
+package model
+
+// ApiKeyRecord model represents the registered API key metadata (see web.IApiKeyStore)
+// @Data
+type ApiKeyRecord struct {
+	KeyId          string   `json:"kid"`                      // Unique API key ID (the kid of the key info)
+	AppName        string   `json:"app"`                      // Application name
+	Description    string   `json:"description,omitempty"`    // Key description (e.g. the key owner)
+	Scopes         []string `json:"scopes,omitempty"`         // Granted scopes (as encrypted in the key)
+	AllowedOrigins []string `json:"allowedOrigins,omitempty"` // Allowed Origin header patterns, same format as CORS (empty for any)
+	AllowedIPs     []string `json:"allowedIps,omitempty"`     // Allowed client IP addresses or CIDR ranges (empty for any)
+	RateLimitTier  string   `json:"tier,omitempty"`           // Rate limit tier name (empty for no tier limit)
+	CreatedAt      int64    `json:"createdAt"`                // Creation time [Epoch milliseconds Timestamp]
+	ExpiresAt      int64    `json:"expiresAt,omitempty"`      // Expiration time [Epoch milliseconds Timestamp] (0 for never)
+	RevokedAt      int64    `json:"revokedAt,omitempty"`      // Revocation time [Epoch milliseconds Timestamp] (0 for active)
+}
+
+// IsRevoked checks if the key was revoked
+func (r *ApiKeyRecord) IsRevoked() bool {
+	return r.RevokedAt > 0
+}
@@ -1,12 +1,49 @@
 package model
 
+import "encoding/json"
+
 // TokenData model represents user info encrypted with the JWT token
 // @Data
 type TokenData struct {
-	AccountId   string `json:"accountId"`   // Account ID
-	SubjectId   string `json:"subjectId"`   // Authenticated subject ID (can be user, or service account)
-	SubjectType int    `json:"subjectType"` // Subject type enum
-	SubjectRole int    `json:"subjectRole"` // Role of user in the account (Role should be specified as Flags (Bitmask)
-	Status      int    `json:"status"`      // User status enum
-	ExpiresIn   int64  `json:"expiresIn"`   // Token expiration [Epoch milliseconds Timestamp]
+	AccountId   string         `json:"accountId"`        // Account ID
+	SubjectId   string         `json:"subjectId"`        // Authenticated subject ID (can be user, or service account)
+	SubjectType int            `json:"subjectType"`      // Subject type enum
+	SubjectRole int            `json:"subjectRole"`      // Role of user in the account (Role should be specified as Flags (Bitmask)
+	Status      int            `json:"status"`           // User status enum
+	ExpiresIn   int64          `json:"expiresIn"`        // Token expiration [Epoch milliseconds Timestamp]
+	TokenId     string         `json:"-"`                // Unique token ID (the jti claim, used for revocation)
+	Claims      map[string]any `json:"claims,omitempty"` // Custom claims (e.g. plan, locale, scopes), see Claim
+}
+
+// WithClaim sets custom claim value (any JSON serializable value)
+func (td *TokenData) WithClaim(name string, value any) *TokenData {
+	if td.Claims == nil {
+		td.Claims = make(map[string]any)
+	}
+	td.Claims[name] = value
+	return td
+}
+
+// Claim returns the custom claim value converted to the requested type
+// Claims of parsed tokens are JSON values (e.g. numbers are float64), they are converted via JSON to the requested type
+func Claim[T any](td *TokenData, name string) (T, bool) {
+	var result T
+	if td == nil {
+		return result, false
+	}
+	value, ok := td.Claims[name]
+	if !ok {
+		return result, false
+	}
+	if typed, ok := value.(T); ok {
+		return typed, true
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return result, false
+	}
+	if err = json.Unmarshal(data, &result); err != nil {
+		return result, false
+	}
+	return result, true
 }
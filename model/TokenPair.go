@@ -0,0 +1,9 @@
+package model
+
+// TokenPair model represents access token and refresh token issued together
+// @Data
+type TokenPair struct {
+	AccessToken  string `json:"accessToken"`  // Access token (JWT)
+	RefreshToken string `json:"refreshToken"` // Refresh token (JWT) to exchange for a new token pair
+	ExpiresIn    int64  `json:"expiresIn"`    // Access token expiration [Epoch milliseconds Timestamp]
+}
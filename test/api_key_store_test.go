@@ -0,0 +1,182 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common-net/model"
+	"github.com/go-yaaf/yaaf-common-net/web"
+)
+
+func TestApiKeyStore(t *testing.T) {
+
+	store := web.NewMemoryApiKeyStore()
+	resolver := web.NewRolePermissionResolver(map[int][]string{testRoles.SUPPORT: {"apikeys:*"}})
+	server := web.NewWebServer().WithSecrets(secret, signing).WithPermissionResolver(resolver).WithApiKeyStore(store).
+		WithRateLimitTiers(map[string]*web.RateLimitPolicy{"basic": {Limit: 1, Period: time.Hour}}).
+		AddRESTEndpoints(web.NewApiKeysEndPoint("/v1/api-keys"), &scopedEndPoint{})
+	url := startTestServer(t, server) + "/v1"
+
+	// Register the admin key directly in the store
+	adminKey, err := server.TokenUtils().CreateApiKey("admin")
+	require.NoError(t, err)
+	info, err := server.TokenUtils().ParseApiKey(adminKey)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(&model.ApiKeyRecord{KeyId: info.KeyId, AppName: info.AppName, CreatedAt: info.IssuedAt}))
+
+	token, err := server.TokenUtils().CreateToken(&model.TokenData{AccountId: "acme", SubjectId: "admin", SubjectRole: testRoles.SUPPORT})
+	require.NoError(t, err)
+
+	create := func(body string) web.ApiKeyResponse {
+		req, er := http.NewRequest(http.MethodPost, url+"/api-keys", strings.NewReader(body))
+		require.NoError(t, er)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-KEY", adminKey)
+		req.Header.Set("X-ACCESS-TOKEN", token)
+		res, er := http.DefaultClient.Do(req)
+		require.NoError(t, er)
+		defer func() { _ = res.Body.Close() }()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		result := web.ApiKeyResponse{}
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&result))
+		return result
+	}
+	call := func(apiKey string) int {
+		return doRequest(t, http.MethodGet, url+"/scoped/heroes", map[string]string{"X-API-KEY": apiKey, "X-ACCESS-TOKEN": token}).StatusCode
+	}
+
+	// Registered key, and unregistered key encrypted with the same secret
+	key := create(`{"app":"heroes","scopes":["heroes:read"]}`)
+	require.Equal(t, "heroes", key.Record.AppName)
+	require.Equal(t, http.StatusOK, call(key.ApiKey))
+	unregistered, err := server.TokenUtils().CreateScopedApiKey("heroes", 0, "heroes:read")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, call(unregistered))
+
+	// Allowed IP ranges
+	remote := create(`{"app":"heroes","scopes":["heroes:read"],"allowedIps":["10.0.0.0/8"]}`)
+	require.Equal(t, http.StatusForbidden, call(remote.ApiKey))
+	local := create(`{"app":"heroes","scopes":["heroes:read"],"allowedIps":["127.0.0.1","::1"]}`)
+	require.Equal(t, http.StatusOK, call(local.ApiKey))
+
+	// Allowed origins
+	browser := create(`{"app":"heroes","scopes":["heroes:read"],"allowedOrigins":["https://*.example.com"]}`)
+	headers := map[string]string{"X-API-KEY": browser.ApiKey, "X-ACCESS-TOKEN": token}
+	headers["Origin"] = "https://app.example.com"
+	require.Equal(t, http.StatusOK, doRequest(t, http.MethodGet, url+"/scoped/heroes", headers).StatusCode)
+	headers["Origin"] = "https://evil.com"
+	require.Equal(t, http.StatusForbidden, doRequest(t, http.MethodGet, url+"/scoped/heroes", headers).StatusCode)
+
+	// Rate limit tier
+	basic := create(`{"app":"heroes","scopes":["heroes:read"],"tier":"basic"}`)
+	require.Equal(t, http.StatusOK, call(basic.ApiKey))
+	require.Equal(t, http.StatusTooManyRequests, call(basic.ApiKey))
+
+	// Revocation
+	res := doRequest(t, http.MethodDelete, url+"/api-keys/"+key.Record.KeyId, map[string]string{"X-API-KEY": adminKey, "X-ACCESS-TOKEN": token})
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, http.StatusForbidden, call(key.ApiKey))
+
+	record, err := store.Get(key.Record.KeyId)
+	require.NoError(t, err)
+	require.True(t, record.IsRevoked())
+
+	list, err := store.List("heroes")
+	require.NoError(t, err)
+	require.Len(t, list, 5)
+}
+
+func TestFileApiKeyStore(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "api-keys.json")
+	store, err := web.NewFileApiKeyStore(path)
+	require.NoError(t, err)
+	require.NoError(t, store.Save(&model.ApiKeyRecord{KeyId: "k1", AppName: "heroes", AllowedIPs: []string{"10.0.0.0/8"}}))
+	require.NoError(t, store.Save(&model.ApiKeyRecord{KeyId: "k2", AppName: "villains"}))
+	require.NoError(t, store.Revoke("k2", time.Now()))
+	require.Error(t, store.Revoke("k3", time.Now()))
+
+	// Reload the file
+	store, err = web.NewFileApiKeyStore(path)
+	require.NoError(t, err)
+	record, err := store.Get("k1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.0/8"}, record.AllowedIPs)
+	record, err = store.Get("k2")
+	require.NoError(t, err)
+	require.True(t, record.IsRevoked())
+	record, err = store.Get("k3")
+	require.NoError(t, err)
+	require.Nil(t, record)
+}
+
+func TestFileApiKeyStoreConcurrentChanges(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "api-keys.json")
+	store, err := web.NewFileApiKeyStore(path)
+	require.NoError(t, err)
+
+	// Every concurrent change is persisted
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			assert.NoError(t, store.Save(&model.ApiKeyRecord{KeyId: id, AppName: "heroes"}))
+			assert.NoError(t, store.Revoke(id, time.Now()))
+		}(fmt.Sprintf("k%d", i))
+	}
+	wg.Wait()
+
+	store, err = web.NewFileApiKeyStore(path)
+	require.NoError(t, err)
+	list, err := store.List("heroes")
+	require.NoError(t, err)
+	require.Len(t, list, 50)
+	for _, record := range list {
+		require.True(t, record.IsRevoked(), record.KeyId)
+	}
+}
+
+// failingApiKeyStore fails every operation with a backend error
+type failingApiKeyStore struct{}
+
+var errStoreBackend = fmt.Errorf("pq: password authentication failed for user admin at 10.0.0.5:5432")
+
+func (f failingApiKeyStore) Get(string) (*model.ApiKeyRecord, error)    { return nil, errStoreBackend }
+func (f failingApiKeyStore) List(string) ([]*model.ApiKeyRecord, error) { return nil, errStoreBackend }
+func (f failingApiKeyStore) Save(*model.ApiKeyRecord) error             { return errStoreBackend }
+func (f failingApiKeyStore) Revoke(string, time.Time) error             { return errStoreBackend }
+
+func TestApiKeyStoreError(t *testing.T) {
+
+	rendered := make(chan error, 10)
+	renderer := func(c *gin.Context, status int, err error) {
+		rendered <- err
+		web.ProblemJSONRenderer(c, status, err)
+	}
+	server := web.NewWebServer().WithSecrets(secret, signing).WithApiKeyStore(failingApiKeyStore{}).WithErrorRenderer(renderer).
+		AddRESTEndpoints(&scopedEndPoint{})
+	url := startTestServer(t, server) + "/v1"
+
+	apiKey, err := server.TokenUtils().CreateApiKey("heroes")
+	require.NoError(t, err)
+	token, err := server.TokenUtils().CreateToken(&model.TokenData{AccountId: "acme", SubjectId: "admin", SubjectRole: testRoles.SUPPORT})
+	require.NoError(t, err)
+
+	// The store error details are logged, not returned to the client (nor to the error renderer)
+	res := doRequest(t, http.MethodGet, url+"/scoped/heroes", map[string]string{"X-API-KEY": apiKey, "X-ACCESS-TOKEN": token})
+	require.Equal(t, http.StatusInternalServerError, res.StatusCode)
+	require.Equal(t, "API key store error", (<-rendered).Error())
+}
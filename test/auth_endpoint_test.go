@@ -0,0 +1,78 @@
+package test
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common-net/model"
+	"github.com/go-yaaf/yaaf-common-net/utils"
+	"github.com/go-yaaf/yaaf-common-net/web"
+)
+
+func TestRefreshTokenFlow(t *testing.T) {
+
+	server := web.NewWebServer().
+		WithSecrets(secret, signing).
+		WithTokenRenewal(false).
+		AddRESTEndpoints(&secureEndPoint{}, web.NewAuthEndPoint("/auth"))
+	url := startTestServer(t, server)
+
+	apiKey, err := server.TokenUtils().CreateApiKey("test")
+	require.NoError(t, err)
+	pair, err := server.TokenUtils().CreateTokenPair(&model.TokenData{AccountId: "acme", SubjectId: "user"})
+	require.NoError(t, err)
+
+	// Access token is accepted (without renewal), refresh token is not an access token
+	res := doRequest(t, http.MethodGet, url+"/v1/secure/me", map[string]string{"X-API-KEY": apiKey, "X-ACCESS-TOKEN": pair.AccessToken})
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Empty(t, res.Header.Get("X-ACCESS-TOKEN"))
+	require.Equal(t, http.StatusUnauthorized, doGet(t, url+"/v1/secure/me", map[string]string{"X-API-KEY": apiKey, "X-ACCESS-TOKEN": pair.RefreshToken}))
+
+	// Exchange refresh token, the refresh token is single use
+	renewed := web.TokenPairResponse{}
+	require.Equal(t, http.StatusOK, doPost(t, url+"/auth/refresh", `{"refreshToken":"`+pair.RefreshToken+`"}`, &renewed))
+	require.NotEmpty(t, renewed.AccessToken)
+	require.NotEqual(t, pair.RefreshToken, renewed.RefreshToken)
+	require.Equal(t, http.StatusUnauthorized, doPost(t, url+"/auth/refresh", `{"refreshToken":"`+pair.RefreshToken+`"}`, &web.ProblemDetails{}))
+
+	// Logout revokes both tokens
+	headers := map[string]string{"X-API-KEY": apiKey, "X-ACCESS-TOKEN": renewed.AccessToken}
+	require.Equal(t, http.StatusOK, doGet(t, url+"/v1/secure/me", headers))
+	req, err := http.NewRequest(http.MethodPost, url+"/auth/logout", strings.NewReader(`{"refreshToken":"`+renewed.RefreshToken+`"}`))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+renewed.AccessToken)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.Equal(t, http.StatusUnauthorized, doGet(t, url+"/v1/secure/me", headers))
+	require.Equal(t, http.StatusUnauthorized, doPost(t, url+"/auth/refresh", `{"refreshToken":"`+renewed.RefreshToken+`"}`, &web.ProblemDetails{}))
+}
+
+func TestConcurrentRefreshToken(t *testing.T) {
+
+	tu := utils.NewTokenUtils().WithSecrets(secret, signing)
+	pair, err := tu.CreateTokenPair(&model.TokenData{AccountId: "acme", SubjectId: "user"})
+	require.NoError(t, err)
+
+	// Only one of the concurrent requests exchanges the refresh token
+	var exchanged atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, er := tu.RefreshToken(pair.RefreshToken); er == nil {
+				exchanged.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(1), exchanged.Load())
+}
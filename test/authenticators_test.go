@@ -0,0 +1,60 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common-net/model"
+	"github.com/go-yaaf/yaaf-common-net/web"
+)
+
+// bearerOrBasicEndPoint accepts Authorization: Bearer or HTTP Basic credentials (no API key)
+type bearerOrBasicEndPoint struct {
+	secureEndPoint
+}
+
+func (e *bearerOrBasicEndPoint) Path() string { return "/v1/partner" }
+
+func (e *bearerOrBasicEndPoint) Authenticators() []web.IAuthenticator {
+	return []web.IAuthenticator{
+		web.AnyOf(
+			web.NewBearerAuthenticator(),
+			web.NewBasicAuthenticator(func(username, password string) (*model.TokenData, error) {
+				if username == "partner" && password == "secret" {
+					return &model.TokenData{SubjectId: username}, nil
+				}
+				return nil, fmt.Errorf("invalid credentials")
+			}),
+		),
+	}
+}
+
+func TestAuthenticatorsChain(t *testing.T) {
+
+	server := web.NewWebServer().WithSecrets(secret, signing).AddRESTEndpoints(&bearerOrBasicEndPoint{})
+	url := startTestServer(t, server) + "/v1/partner/me"
+
+	token, err := server.TokenUtils().CreateToken(&model.TokenData{SubjectId: "service@email.com"})
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusOK, doGet(t, url, map[string]string{"Authorization": "Bearer " + token}))
+	require.Equal(t, http.StatusUnauthorized, doGet(t, url, map[string]string{"Authorization": "Bearer invalid"}))
+	require.Equal(t, http.StatusUnauthorized, doGet(t, url, map[string]string{"X-ACCESS-TOKEN": token}))
+
+	// Basic credentials
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.SetBasicAuth("partner", "secret")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	req.SetBasicAuth("partner", "wrong")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
@@ -0,0 +1,76 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common-net/web"
+)
+
+// doRequest invokes HTTP request with the provided headers and returns the response (body closed)
+func doRequest(t *testing.T, method, url string, headers map[string]string) *http.Response {
+	req, err := http.NewRequest(method, url, nil)
+	require.NoError(t, err)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	return resp
+}
+
+func TestCORSPolicy(t *testing.T) {
+
+	server := web.NewWebServer().
+		WithSecrets(secret, signing).
+		WithCORS(web.CORSPolicy{
+			AllowedOrigins:   []string{"https://console.example.com", "https://*.tenant.io", `regex:^http://localhost:\d+$`},
+			AllowedMethods:   []string{"GET", "POST"},
+			AllowedHeaders:   []string{"Content-Type", "X-ACCESS-TOKEN"},
+			ExposedHeaders:   []string{"X-ACCESS-TOKEN"},
+			AllowCredentials: true,
+			MaxAge:           10 * time.Minute,
+		}).
+		AddRESTEndpoints(&slowEndPoint{})
+
+	url := startTestServer(t, server) + "/v1/test/slow"
+
+	// Preflight from allowed origins
+	for _, origin := range []string{"https://console.example.com", "https://acme.tenant.io", "http://localhost:4200"} {
+		resp := doRequest(t, http.MethodOptions, url, map[string]string{"Origin": origin, "Access-Control-Request-Method": "GET"})
+		require.Equal(t, http.StatusNoContent, resp.StatusCode, origin)
+		require.Equal(t, origin, resp.Header.Get("Access-Control-Allow-Origin"))
+		require.Equal(t, "true", resp.Header.Get("Access-Control-Allow-Credentials"))
+		require.Equal(t, "GET,POST", resp.Header.Get("Access-Control-Allow-Methods"))
+		require.Equal(t, "Content-Type, X-ACCESS-TOKEN", resp.Header.Get("Access-Control-Allow-Headers"))
+		require.Equal(t, "600", resp.Header.Get("Access-Control-Max-Age"))
+	}
+
+	// Preflight from disallowed origins
+	for _, origin := range []string{"https://evil.com", "https://tenant.io", "http://localhost"} {
+		resp := doRequest(t, http.MethodOptions, url, map[string]string{"Origin": origin, "Access-Control-Request-Method": "GET"})
+		require.Equal(t, http.StatusForbidden, resp.StatusCode, origin)
+		require.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+		require.Equal(t, "Origin", resp.Header.Get("Vary"))
+	}
+
+	// Actual request
+	resp := doRequest(t, http.MethodGet, url, map[string]string{"Origin": "https://console.example.com"})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "https://console.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "X-ACCESS-TOKEN", resp.Header.Get("Access-Control-Expose-Headers"))
+
+	resp = doRequest(t, http.MethodGet, url, map[string]string{"Origin": "https://evil.com"})
+	require.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+	require.Empty(t, resp.Header.Get("Access-Control-Allow-Credentials"))
+	require.Equal(t, "Origin", resp.Header.Get("Vary"))
+
+	// Credentials can not be allowed for any origin
+	require.Panics(t, func() {
+		web.NewWebServer().WithCORS(web.CORSPolicy{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+	})
+}
@@ -0,0 +1,142 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common-net/web"
+)
+
+type createHeroRequest struct {
+	AccountId string `uri:"accountId"`
+	DryRun    bool   `form:"dry"`
+	Name      string `json:"name" binding:"required"`
+	Power     int    `json:"power" binding:"gte=0,lte=100"`
+}
+
+type profileRequest struct {
+	Id    string `form:"id"`
+	Name  string `json:"name"`
+	Admin bool   `json:"admin"`
+}
+
+type typedEndPoint struct{}
+
+func (e *typedEndPoint) Path() string { return "/v1/typed" }
+
+func (e *typedEndPoint) RestEntries() []web.RestEntry {
+	return []web.RestEntry{
+		{Method: http.MethodPost, Path: "/:accountId/heroes", Handler: web.Handle(e.createHero), Skip: web.TOKEN},
+		{Method: http.MethodPost, Path: "/profile", Handler: web.Handle(e.profile), Skip: web.TOKEN},
+		{Method: http.MethodPost, Path: "/fail", Handler: web.Handle(e.fail), Skip: web.TOKEN},
+		{Method: http.MethodPost, Path: "/panic", Handler: func(c *gin.Context) { panic("boom") }, Skip: web.TOKEN},
+	}
+}
+
+func (e *typedEndPoint) createHero(c *gin.Context, req createHeroRequest) (*web.ActionResponse, error) {
+	if req.AccountId == "locked" {
+		return nil, web.NewForbiddenError("account %s is locked", req.AccountId)
+	}
+	return web.NewActionResponse(req.Name, fmt.Sprintf("%s:%d:%v", req.AccountId, req.Power, req.DryRun)), nil
+}
+
+func (e *typedEndPoint) profile(c *gin.Context, req profileRequest) (*profileRequest, error) {
+	return &req, nil
+}
+
+type failRequest struct {
+	Kind string `form:"kind"`
+}
+
+func (e *typedEndPoint) fail(c *gin.Context, req failRequest) (*web.ActionResponse, error) {
+	switch req.Kind {
+	case "internal":
+		return nil, web.NewInternalError("store error: dial tcp 10.0.0.7:5432: connection refused")
+	case "exposed":
+		return nil, web.NewHttpError(http.StatusServiceUnavailable, "maintenance until 10:00").Expose()
+	default:
+		return nil, fmt.Errorf("dial tcp 10.0.0.7:5432: connection refused")
+	}
+}
+
+// doPost invokes POST request with JSON body and returns the status code and decoded response
+func doPost(t *testing.T, url, body string, result any) int {
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(result))
+	return resp.StatusCode
+}
+
+func TestTypedHandler(t *testing.T) {
+
+	server := web.NewWebServer().WithSecrets(secret, signing).AddRESTEndpoints(&typedEndPoint{})
+	url := startTestServer(t, server) + "/v1/typed"
+
+	ar := web.ActionResponse{}
+	require.Equal(t, http.StatusOK, doPost(t, url+"/acme/heroes?dry=true", `{"name":"Batman","power":90}`, &ar))
+	require.Equal(t, "Batman", ar.Key)
+	require.Equal(t, "acme:90:true", ar.Data)
+
+	// Validation error
+	pd := web.ProblemDetails{}
+	require.Equal(t, http.StatusBadRequest, doPost(t, url+"/acme/heroes", `{"power":90}`, &pd))
+	require.Equal(t, http.StatusBadRequest, pd.Status)
+	require.Equal(t, "/v1/typed/acme/heroes", pd.Instance)
+	require.Len(t, pd.Errors, 1)
+	require.Equal(t, "Name", pd.Errors[0].Field)
+
+	// Invalid query parameter type
+	pd = web.ProblemDetails{}
+	require.Equal(t, http.StatusBadRequest, doPost(t, url+"/acme/heroes?dry=maybe", `{"name":"Batman"}`, &pd))
+
+	// Body fields without form tag are not overridden by the query params
+	pr := profileRequest{}
+	require.Equal(t, http.StatusOK, doPost(t, url+"/profile?id=7&Name=evil&Admin=true", `{"name":"good"}`, &pr))
+	require.Equal(t, profileRequest{Id: "7", Name: "good"}, pr)
+
+	// Handler error mapped to HTTP status
+	pd = web.ProblemDetails{}
+	require.Equal(t, http.StatusForbidden, doPost(t, url+"/locked/heroes", `{"name":"Batman"}`, &pd))
+	require.Equal(t, "Forbidden", pd.Title)
+	require.Equal(t, "account locked is locked", pd.Detail)
+
+	// Internal error details are not exposed
+	pd = web.ProblemDetails{}
+	require.Equal(t, http.StatusInternalServerError, doPost(t, url+"/fail", `{}`, &pd))
+	require.Equal(t, "internal server error", pd.Detail)
+	pd = web.ProblemDetails{}
+	require.Equal(t, http.StatusInternalServerError, doPost(t, url+"/fail?kind=internal", `{}`, &pd))
+	require.Equal(t, "internal server error", pd.Detail)
+
+	// Explicitly exposed message of internal failure
+	pd = web.ProblemDetails{}
+	require.Equal(t, http.StatusServiceUnavailable, doPost(t, url+"/fail?kind=exposed", `{}`, &pd))
+	require.Equal(t, "maintenance until 10:00", pd.Detail)
+
+	// Panic recovered, the panic value is not exposed
+	pd = web.ProblemDetails{}
+	require.Equal(t, http.StatusInternalServerError, doPost(t, url+"/panic", `{}`, &pd))
+	require.Equal(t, "internal server error", pd.Detail)
+}
+
+func TestLegacyErrorRenderer(t *testing.T) {
+
+	server := web.NewWebServer().WithSecrets(secret, signing).WithErrorRenderer(web.LegacyErrorRenderer).AddRESTEndpoints(&typedEndPoint{})
+	url := startTestServer(t, server) + "/v1/typed"
+
+	br := web.BaseRestResponse{}
+	require.Equal(t, http.StatusForbidden, doPost(t, url+"/locked/heroes", `{"name":"Batman"}`, &br))
+	require.Equal(t, -1, br.Code)
+	require.Equal(t, "account locked is locked", br.Error)
+
+	br = web.BaseRestResponse{}
+	require.Equal(t, http.StatusInternalServerError, doPost(t, url+"/fail", `{}`, &br))
+	require.Equal(t, "internal server error", br.Error)
+}
@@ -0,0 +1,125 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common-net/model"
+	"github.com/go-yaaf/yaaf-common-net/utils"
+	"github.com/go-yaaf/yaaf-common-net/web"
+)
+
+// privateKeyPEM encodes the private key in PKCS8 PEM format
+func privateKeyPEM(t *testing.T, key any) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	td := &model.TokenData{AccountId: "acme", SubjectId: "user"}
+	issuer := utils.NewTokenUtils().WithSecrets(secret, signing)
+	verifier := utils.NewTokenUtils()
+
+	for kid, key := range map[string]any{"RS256": rsaKey, "ES256": ecKey, "EdDSA": edKey} {
+		require.NoError(t, issuer.RotateSigningKeyPEM(kid, privateKeyPEM(t, key), 0), kid)
+		token, err := issuer.CreateToken(td)
+		require.NoError(t, err, kid)
+
+		// The token header includes the algorithm and the key ID
+		parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+		require.NoError(t, err)
+		require.Equal(t, kid, parsed.Method.Alg())
+		require.Equal(t, kid, parsed.Header["kid"])
+
+		// The verifier accepts the token after loading the published keys
+		_, err = verifier.ParseToken(token)
+		require.Error(t, err, kid)
+		require.NoError(t, verifier.AddJWKS(issuer.JWKS()))
+		actual, err := verifier.ParseToken(token)
+		require.NoError(t, err, kid)
+		require.Equal(t, td.SubjectId, actual.SubjectId)
+	}
+
+	// Only the public asymmetric keys are published
+	require.Len(t, issuer.JWKS().Keys, 3)
+
+	// Keys of other issuers are not published
+	require.Len(t, verifier.JWKS().Keys, 0)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&other.PublicKey)
+	require.NoError(t, err)
+	otherPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	require.NoError(t, issuer.AddVerificationKeyPEM("other", otherPEM, time.Time{}))
+	require.Len(t, issuer.JWKS().Keys, 3)
+
+	// Keys of other issuers can't replace the signing keys
+	published := verifier.JWKS()
+	published.Keys = issuer.JWKS().Keys[:1]
+	published.Keys[0].Kid = ""
+	require.Error(t, issuer.AddJWKS(published))
+	published.Keys[0].Kid = "EdDSA"
+	require.Error(t, issuer.AddJWKS(published))
+	require.Error(t, issuer.AddVerificationKeyPEM("", otherPEM, time.Time{}))
+	require.Error(t, issuer.AddVerificationKeyPEM("EdDSA", otherPEM, time.Time{}))
+	_, err = issuer.CreateToken(td)
+	require.NoError(t, err)
+	require.Len(t, issuer.JWKS().Keys, 3)
+}
+
+func TestJWKSEndpoint(t *testing.T) {
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	issuer := web.NewWebServer().WithSecrets(secret, signing).WithJWKSEndpoint()
+	require.NoError(t, issuer.TokenUtils().RotateSigningKeyPEM("issuer-1", privateKeyPEM(t, ecKey), 0))
+	issuerUrl := startTestServer(t, issuer)
+
+	// Download the published keys to a file
+	res, err := http.Get(issuerUrl + "/.well-known/jwks.json")
+	require.NoError(t, err)
+	set := utils.JSONWebKeySet{}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&set))
+	_ = res.Body.Close()
+	require.Len(t, set.Keys, 1)
+	require.Equal(t, "ES384", set.Keys[0].Alg)
+
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(file, data, 0600))
+
+	// Downstream service verifies the issued tokens (the imported keys are kept by WithSecrets)
+	service := web.NewWebServer().WithJWKSFile(file).WithSecrets(secret, signing).AddRESTEndpoints(&secureEndPoint{})
+	serviceUrl := startTestServer(t, service)
+
+	apiKey, err := service.TokenUtils().CreateApiKey("test")
+	require.NoError(t, err)
+	token, err := issuer.TokenUtils().CreateToken(&model.TokenData{AccountId: "acme", SubjectId: "user"})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, doGet(t, serviceUrl+"/v1/secure/me", map[string]string{"X-API-KEY": apiKey, "X-ACCESS-TOKEN": token}))
+}
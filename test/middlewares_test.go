@@ -0,0 +1,81 @@
+package test
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common-net/web"
+)
+
+type middlewaresEndPoint struct {
+	invoked atomic.Int32
+}
+
+func (e *middlewaresEndPoint) Path() string { return "/v1/chain" }
+
+func (e *middlewaresEndPoint) Middlewares() []gin.HandlerFunc {
+	return []gin.HandlerFunc{e.trace("endpoint")}
+}
+
+func (e *middlewaresEndPoint) RestEntries() []web.RestEntry {
+	return []web.RestEntry{
+		{Method: http.MethodGet, Path: "/open", Handler: e.handler, Skip: web.TOKEN, Middlewares: []gin.HandlerFunc{e.trace("entry")}},
+		{Method: http.MethodGet, Path: "/gated", Handler: e.handler, Skip: web.TOKEN, Middlewares: []gin.HandlerFunc{featureGate(false)}},
+		{Method: http.MethodGet, Path: "/secure", Handler: e.handler},
+	}
+}
+
+// trace appends the middleware name to the X-TRACE response header
+func (e *middlewaresEndPoint) trace(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		e.invoked.Add(1)
+		c.Set("trace", append(c.GetStringSlice("trace"), name))
+	}
+}
+
+func (e *middlewaresEndPoint) handler(c *gin.Context) {
+	c.Header("X-TRACE", strings.Join(append(c.GetStringSlice("trace"), "handler"), ","))
+	c.JSON(http.StatusOK, web.NewActionResponse("ok", ""))
+}
+
+// featureGate rejects the request when the feature is disabled
+func featureGate(enabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !enabled {
+			web.RenderError(c, web.NewNotFoundError("feature disabled"))
+		}
+	}
+}
+
+func TestMiddlewaresChain(t *testing.T) {
+
+	ep := &middlewaresEndPoint{}
+	server := web.NewWebServer().WithSecrets(secret, signing).AddRESTEndpoints(ep)
+	url := startTestServer(t, server) + "/v1/chain"
+
+	// Endpoint middlewares are invoked before the entry middlewares
+	res := doRequest(t, http.MethodGet, url+"/open", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "endpoint,entry,handler", res.Header.Get("X-TRACE"))
+
+	// Entry middleware may abort the request
+	res = doRequest(t, http.MethodGet, url+"/gated", nil)
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+	require.Empty(t, res.Header.Get("X-TRACE"))
+
+	// Unauthenticated requests are rejected before the endpoint middlewares
+	ep.invoked.Store(0)
+	res = doRequest(t, http.MethodGet, url+"/secure", nil)
+	require.Equal(t, http.StatusForbidden, res.StatusCode)
+	require.Equal(t, int32(0), ep.invoked.Load())
+
+	// Preflight requests are handled by the server middlewares only
+	res = doRequest(t, http.MethodOptions, url+"/open", map[string]string{"Origin": "http://localhost"})
+	require.Equal(t, http.StatusNoContent, res.StatusCode)
+	require.Equal(t, int32(0), ep.invoked.Load())
+}
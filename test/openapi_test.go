@@ -0,0 +1,139 @@
+package test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common-net/web"
+)
+
+type docHero struct {
+	Id     string   `json:"id"`
+	Name   string   `json:"name"`
+	Powers []string `json:"powers"`
+	Rank   int64    `json:"rank"`
+	secret string
+}
+
+type docHeroResponse struct {
+	web.BaseRestResponse
+	Hero *docHero `json:"hero"`
+}
+
+type docHeroQuery struct {
+	Name  string `form:"name"`
+	Limit int    `form:"limit"`
+}
+
+type docEndPoint struct{}
+
+func (e *docEndPoint) Path() string          { return "/v1/heroes" }
+func (e *docEndPoint) ResourceGroup() string { return "Heroes" }
+
+func (e *docEndPoint) RestEntries() []web.RestEntry {
+	return []web.RestEntry{
+		{Method: http.MethodGet, Path: "/:id", Handler: e.handler, Summary: "Get hero", Response: docHeroResponse{}},
+		{Method: http.MethodGet, Path: "", Handler: e.handler, Skip: web.TOKEN, Request: docHeroQuery{}},
+		{Method: http.MethodPost, Path: "", Handler: e.handler, Skip: web.APIKEY, Request: docHero{}, Response: docHeroResponse{}},
+	}
+}
+
+func (e *docEndPoint) handler(c *gin.Context) {}
+
+func TestOpenAPIDocument(t *testing.T) {
+
+	server := web.NewWebServer().
+		WithSecrets(secret, signing).
+		WithAPIVersion("2.0.0").
+		AddRESTEndpoints(&docEndPoint{}).
+		WithOpenAPI("/api-docs", web.OpenAPIInfo{Title: "Heroes API"})
+
+	doc := server.OpenAPIDocument()
+	require.Equal(t, "3.0.3", doc.OpenAPI)
+	require.Equal(t, "Heroes API", doc.Info.Title)
+	require.Equal(t, "2.0.0", doc.Info.Version)
+
+	// Path parameters and response schema
+	get := doc.Paths["/v1/heroes/{id}"]["get"]
+	require.NotNil(t, get)
+	require.Equal(t, []string{"Heroes"}, get.Tags)
+	require.Equal(t, "Get hero", get.Summary)
+	require.Equal(t, "id", get.Parameters[0].Name)
+	require.Equal(t, "path", get.Parameters[0].In)
+	require.Equal(t, "#/components/schemas/docHeroResponse", get.Responses["200"].Content["application/json"].Schema.Ref)
+	require.Len(t, get.Security, 1)
+	require.Contains(t, get.Security[0], "ApiKey")
+	require.Contains(t, get.Security[0], "AccessToken")
+
+	// Embedded structures are flattened, unexported fields are ignored
+	resp := doc.Components.Schemas["docHeroResponse"]
+	require.Contains(t, resp.Properties, "code")
+	require.Contains(t, resp.Properties, "hero")
+	hero := doc.Components.Schemas["docHero"]
+	require.Equal(t, "array", hero.Properties["powers"].Type)
+	require.Equal(t, "int64", hero.Properties["rank"].Format)
+	require.NotContains(t, hero.Properties, "secret")
+
+	// Query parameters and public entry
+	list := doc.Paths["/v1/heroes"]["get"]
+	require.Len(t, list.Parameters, 2)
+	require.Empty(t, list.Security)
+
+	// Request body and partial security
+	post := doc.Paths["/v1/heroes"]["post"]
+	require.Equal(t, "#/components/schemas/docHero", post.RequestBody.Content["application/json"].Schema.Ref)
+	require.NotContains(t, post.Security[0], "ApiKey")
+
+	// Served documents
+	url := startTestServer(t, server)
+	for _, path := range []string{"/api-docs/openapi.json", "/api-docs/openapi.yaml", "/api-docs"} {
+		res, err := http.Get(url + path)
+		require.NoError(t, err)
+		body, _ := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode, path)
+		require.Contains(t, string(body), "openapi", path)
+	}
+
+	// Swagger UI assets are served by the server
+	res, err := http.Get(url + "/api-docs")
+	require.NoError(t, err)
+	page, _ := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	require.NotContains(t, string(page), "https://")
+	for _, path := range []string{"/api-docs/assets/swagger-ui.css", "/api-docs/assets/swagger-ui-bundle.js", "/api-docs/assets/swagger-initializer.js"} {
+		require.Contains(t, string(page), path)
+		require.Equal(t, http.StatusOK, doGet(t, url+path, nil), path)
+	}
+}
+
+func TestOpenAPISecuritySchemes(t *testing.T) {
+
+	server := web.NewWebServer().
+		WithAuthenticators(web.NewApiKeyAuthenticator(""), web.AnyOf(web.NewBearerAuthenticator(), web.NewCookieAuthenticator("session"))).
+		AddRESTEndpoints(&docEndPoint{})
+
+	// Only the schemes of the configured authenticators are documented
+	doc := server.OpenAPIDocument()
+	require.Len(t, doc.Components.SecuritySchemes, 3)
+	require.Equal(t, "bearer", doc.Components.SecuritySchemes["Bearer"].Scheme)
+	require.Equal(t, "cookie", doc.Components.SecuritySchemes["Cookie"].In)
+	require.Equal(t, "session", doc.Components.SecuritySchemes["Cookie"].Name)
+	require.NotContains(t, doc.Components.SecuritySchemes, "AccessToken")
+
+	// API key and any of the token authenticators
+	get := doc.Paths["/v1/heroes/{id}"]["get"]
+	require.Equal(t, []map[string][]string{
+		{"ApiKey": {}, "Bearer": {}},
+		{"ApiKey": {}, "Cookie": {}},
+	}, get.Security)
+
+	// Token authenticators skipped
+	post := doc.Paths["/v1/heroes"]["post"]
+	require.Len(t, post.Security, 2)
+	require.Empty(t, doc.Paths["/v1/heroes"]["get"].Security)
+}
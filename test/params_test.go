@@ -0,0 +1,85 @@
+package test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-yaaf/yaaf-common/entity"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common-net/web"
+)
+
+type testRole struct {
+	SALES   int
+	SUPPORT int
+	FINANCE int
+}
+
+var testRoles = &testRole{SALES: 1, SUPPORT: 4, FINANCE: 8}
+
+type pagingParams struct {
+	Page int `param:"page" default:"0"`
+	Size int `param:"size" default:"100"`
+}
+
+type searchParams struct {
+	pagingParams
+	Id     string           `param:"id,required"`
+	Search string           `param:"search"`
+	From   entity.Timestamp `param:"from" default:"-3600000"`
+	To     entity.Timestamp `param:"to" default:"0"`
+	Role   int              `param:"role" enum:"TestRoles"`
+	Roles  []int            `param:"roles" enum:"TestRoles"`
+	Tags   []string         `param:"tags"`
+	Ratio  *float64         `param:"ratio"`
+	Active bool             `param:"active" default:"true"`
+}
+
+// newParamsContext creates gin context for the provided query string and path params
+func newParamsContext(query string, params gin.Params) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/v1/search"+query, nil)
+	c.Params = params
+	return c
+}
+
+func TestBindParams(t *testing.T) {
+
+	web.RegisterEnum("TestRoles", testRoles)
+	ep := web.BaseEndPoint{}
+
+	c := newParamsContext("?search=%20bat%20&roles=SALES,8&roles=SUPPORT&tags=a,b&tags=c&role=FINANCE&ratio=0.5&page=2", gin.Params{{Key: "id", Value: "42"}})
+	p := searchParams{}
+	require.NoError(t, ep.BindParams(c, &p))
+
+	now := entity.Now()
+	require.Equal(t, "42", p.Id)
+	require.Equal(t, "bat", p.Search)
+	require.Equal(t, 2, p.Page)
+	require.Equal(t, 100, p.Size)
+	require.InDelta(t, int64(now-3600000), int64(p.From), 1000)
+	require.Equal(t, entity.Timestamp(0), p.To)
+	require.Equal(t, 8, p.Role)
+	require.Equal(t, []int{1, 8, 4}, p.Roles)
+	require.Equal(t, []string{"a", "b", "c"}, p.Tags)
+	require.Equal(t, 0.5, *p.Ratio)
+	require.True(t, p.Active)
+
+	// All invalid parameters are reported
+	c = newParamsContext("?page=x&from=yesterday&role=CEO&roles=1,BOSS&active=maybe", nil)
+	p = searchParams{}
+	err := ep.BindParams(c, &p)
+	require.Error(t, err)
+
+	var ve *web.ValidationError
+	require.True(t, errors.As(err, &ve))
+	fields := make([]string, 0)
+	for _, fe := range ve.Errors {
+		fields = append(fields, fe.Field)
+	}
+	require.ElementsMatch(t, []string{"page", "id", "from", "role", "roles", "active"}, fields)
+}
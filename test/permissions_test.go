@@ -0,0 +1,102 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common-net/model"
+	"github.com/go-yaaf/yaaf-common-net/web"
+)
+
+type accountsEndPoint struct{}
+
+func (e *accountsEndPoint) Path() string { return "/v1/accounts" }
+
+func (e *accountsEndPoint) RestEntries() []web.RestEntry {
+	return []web.RestEntry{
+		{Method: http.MethodGet, Path: "/:accountId/heroes", Handler: e.ok, Skip: web.APIKEY,
+			Permissions: []string{"heroes:read"}, Checks: []web.AccessCheck{web.MatchAccountParam("accountId", "accounts:admin")}},
+		{Method: http.MethodPost, Path: "/:accountId/heroes", Handler: e.ok, Skip: web.APIKEY,
+			Permissions: []string{"heroes:write"}},
+	}
+}
+
+func (e *accountsEndPoint) ok(c *gin.Context) {
+	c.JSON(http.StatusOK, web.NewActionResponse("ok", ""))
+}
+
+func TestPermissions(t *testing.T) {
+
+	resolver := web.NewRolePermissionResolver(map[int][]string{
+		testRoles.SALES:   {"heroes:read"},
+		testRoles.SUPPORT: {"heroes:*"},
+		testRoles.FINANCE: {"accounts:admin", "heroes:read"},
+	})
+	server := web.NewWebServer().WithSecrets(secret, signing).WithPermissionResolver(resolver).AddRESTEndpoints(&accountsEndPoint{})
+	url := startTestServer(t, server) + "/v1/accounts"
+
+	call := func(method, path string, role int) int {
+		token, err := server.TokenUtils().CreateToken(&model.TokenData{AccountId: "acme", SubjectId: "user", SubjectRole: role})
+		require.NoError(t, err)
+		res := doRequest(t, method, url+path, map[string]string{"X-ACCESS-TOKEN": token})
+		return res.StatusCode
+	}
+
+	// Permission by role
+	require.Equal(t, http.StatusOK, call(http.MethodGet, "/acme/heroes", testRoles.SALES))
+	require.Equal(t, http.StatusForbidden, call(http.MethodPost, "/acme/heroes", testRoles.SALES))
+
+	// Wildcard permission
+	require.Equal(t, http.StatusOK, call(http.MethodPost, "/acme/heroes", testRoles.SUPPORT))
+
+	// Account attribute check, and bypass permission
+	require.Equal(t, http.StatusForbidden, call(http.MethodGet, "/other/heroes", testRoles.SALES))
+	require.Equal(t, http.StatusOK, call(http.MethodGet, "/other/heroes", testRoles.FINANCE))
+
+	// No token
+	require.Equal(t, http.StatusUnauthorized, doRequest(t, http.MethodGet, url+"/acme/heroes", nil).StatusCode)
+}
+
+type scopedEndPoint struct {
+	web.BaseEndPoint
+}
+
+func (e *scopedEndPoint) Path() string { return "/v1/scoped" }
+
+func (e *scopedEndPoint) RestEntries() []web.RestEntry {
+	return []web.RestEntry{
+		{Method: http.MethodGet, Path: "/heroes", Handler: e.app, Scopes: []string{"heroes:read"}},
+		{Method: http.MethodPost, Path: "/heroes", Handler: e.app, Scopes: []string{"heroes:write"}},
+	}
+}
+
+// app returns the application name of the API key
+func (e *scopedEndPoint) app(c *gin.Context) {
+	c.JSON(http.StatusOK, web.NewActionResponse(e.GetApiKeyInfo(c).AppName, ""))
+}
+
+func TestApiKeyScopes(t *testing.T) {
+
+	server := web.NewWebServer().WithSecrets(secret, signing).AddRESTEndpoints(&scopedEndPoint{})
+	url := startTestServer(t, server) + "/v1/scoped/heroes"
+
+	token, err := server.TokenUtils().CreateToken(&model.TokenData{AccountId: "acme", SubjectId: "user"})
+	require.NoError(t, err)
+	readKey, err := server.TokenUtils().CreateScopedApiKey("reader", 0, "heroes:read")
+	require.NoError(t, err)
+	allKey, err := server.TokenUtils().CreateScopedApiKey("admin", 0, "heroes:*")
+	require.NoError(t, err)
+	noScopes, err := server.TokenUtils().CreateApiKey("plain")
+	require.NoError(t, err)
+
+	call := func(method, apiKey string) int {
+		return doRequest(t, method, url, map[string]string{"X-API-KEY": apiKey, "X-ACCESS-TOKEN": token}).StatusCode
+	}
+	require.Equal(t, http.StatusOK, call(http.MethodGet, readKey))
+	require.Equal(t, http.StatusForbidden, call(http.MethodPost, readKey))
+	require.Equal(t, http.StatusOK, call(http.MethodPost, allKey))
+	require.Equal(t, http.StatusForbidden, call(http.MethodGet, noScopes))
+}
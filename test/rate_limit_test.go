@@ -0,0 +1,104 @@
+package test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common-net/model"
+	"github.com/go-yaaf/yaaf-common-net/web"
+)
+
+type limitedEndPoint struct{}
+
+func (e *limitedEndPoint) Path() string { return "/v1/limited" }
+
+func (e *limitedEndPoint) RestEntries() []web.RestEntry {
+	return []web.RestEntry{
+		{Method: http.MethodGet, Path: "/ip", Handler: e.ok, Skip: web.TOKEN, RateLimit: &web.RateLimitPolicy{Limit: 2, Period: time.Hour}},
+		{Method: http.MethodGet, Path: "/subject", Handler: e.ok, Skip: web.APIKEY, RateLimit: &web.RateLimitPolicy{Limit: 1, Period: time.Hour, Key: web.RateLimitBySubject}},
+		{Method: http.MethodGet, Path: "/free", Handler: e.ok, Skip: web.TOKEN},
+	}
+}
+
+func (e *limitedEndPoint) ok(c *gin.Context) {
+	c.JSON(http.StatusOK, web.NewActionResponse("ok", ""))
+}
+
+func TestRateLimit(t *testing.T) {
+
+	server := web.NewWebServer().WithSecrets(secret, signing).AddRESTEndpoints(&limitedEndPoint{})
+	url := startTestServer(t, server) + "/v1/limited"
+
+	// Limit by IP
+	for i, expected := range []string{"1", "0"} {
+		res := doRequest(t, http.MethodGet, url+"/ip", nil)
+		require.Equal(t, http.StatusOK, res.StatusCode, i)
+		require.Equal(t, "2", res.Header.Get("RateLimit-Limit"))
+		require.Equal(t, expected, res.Header.Get("RateLimit-Remaining"))
+	}
+	res := doRequest(t, http.MethodGet, url+"/ip", nil)
+	require.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+	require.Equal(t, "application/problem+json", res.Header.Get("Content-Type"))
+	require.Equal(t, "1800", res.Header.Get("Retry-After"))
+
+	// The X-Forwarded-For header of untrusted clients is ignored
+	res = doRequest(t, http.MethodGet, url+"/ip", map[string]string{"X-Forwarded-For": "203.0.113.7"})
+	require.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+
+	// Limit by subject, each subject has its own bucket
+	for _, subject := range []string{"batman", "robin"} {
+		token, err := server.TokenUtils().CreateToken(&model.TokenData{SubjectId: subject})
+		require.NoError(t, err)
+		headers := map[string]string{"X-ACCESS-TOKEN": token}
+		require.Equal(t, http.StatusOK, doGet(t, url+"/subject", headers), subject)
+		require.Equal(t, http.StatusTooManyRequests, doGet(t, url+"/subject", headers), subject)
+	}
+
+	// Entries without policy are not limited
+	for i := 0; i < 5; i++ {
+		res = doRequest(t, http.MethodGet, url+"/free", nil)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Empty(t, res.Header.Get("RateLimit-Limit"))
+	}
+}
+
+func TestRateLimitTrustedProxy(t *testing.T) {
+
+	server := web.NewWebServer().WithSecrets(secret, signing).WithTrustedProxies("127.0.0.1").AddRESTEndpoints(&limitedEndPoint{})
+	url := startTestServer(t, server) + "/v1/limited"
+
+	// The client IP is taken from the X-Forwarded-For header of the trusted proxy
+	for _, ip := range []string{"203.0.113.7", "203.0.113.8"} {
+		headers := map[string]string{"X-Forwarded-For": ip}
+		require.Equal(t, http.StatusOK, doGet(t, url+"/ip", headers), ip)
+		require.Equal(t, http.StatusOK, doGet(t, url+"/ip", headers), ip)
+		require.Equal(t, http.StatusTooManyRequests, doGet(t, url+"/ip", headers), ip)
+	}
+}
+
+func TestRateLimitFailedAuthentication(t *testing.T) {
+
+	server := web.NewWebServer().WithSecrets(secret, signing).WithTrustedProxies("127.0.0.1").AddRESTEndpoints(&limitedEndPoint{})
+	url := startTestServer(t, server) + "/v1/limited"
+
+	token, err := server.TokenUtils().CreateToken(&model.TokenData{SubjectId: "batman"})
+	require.NoError(t, err)
+	attacker := map[string]string{"X-Forwarded-For": "203.0.113.7", "X-ACCESS-TOKEN": "guessed"}
+
+	// Failed authentications are counted per client IP with the entry policy, although it is keyed by subject
+	require.Equal(t, http.StatusUnauthorized, doGet(t, url+"/subject", attacker))
+	require.Equal(t, http.StatusUnauthorized, doGet(t, url+"/subject", attacker))
+
+	// The client IP is blocked before authentication, even with valid credentials
+	attacker["X-ACCESS-TOKEN"] = token
+	res := doRequest(t, http.MethodGet, url+"/subject", attacker)
+	require.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+	require.Equal(t, "3600", res.Header.Get("Retry-After"))
+
+	// Other clients are not blocked
+	require.Equal(t, http.StatusOK, doGet(t, url+"/subject", map[string]string{"X-Forwarded-For": "203.0.113.8", "X-ACCESS-TOKEN": token}))
+}
@@ -0,0 +1,97 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common-net/model"
+	"github.com/go-yaaf/yaaf-common-net/web"
+)
+
+type routesEndPoint struct{}
+
+func (e *routesEndPoint) Path() string { return "/v1/routes" }
+
+func (e *routesEndPoint) RestEntries() []web.RestEntry {
+	return []web.RestEntry{
+		{Method: http.MethodGet, Path: "/items/:id", Handler: e.route, Skip: web.TOKEN},
+		{Method: http.MethodGet, Path: "/items/new", Handler: e.route, Skip: web.TOKEN},
+		{Method: http.MethodGet, Path: "/items/:id/tags", Handler: e.route, Skip: web.TOKEN},
+		{Method: http.MethodGet, Path: "/files/*path", Handler: e.route, Skip: web.TOKEN},
+		{Method: http.MethodPost, Path: "/items/:id", Handler: e.route, Skip: web.APIKEY, Role: 4},
+	}
+}
+
+// route returns the resolved route template
+func (e *routesEndPoint) route(c *gin.Context) {
+	ri := web.GetRouteInfo(c)
+	if ri == nil {
+		c.JSON(http.StatusOK, web.NewActionResponse("", ""))
+		return
+	}
+	c.JSON(http.StatusOK, web.NewActionResponse(ri.Path, ri.Method))
+}
+
+func TestRouteMetadata(t *testing.T) {
+
+	server := web.NewWebServer().WithSecrets(secret, signing).AddRESTEndpoints(&routesEndPoint{})
+	url := startTestServer(t, server) + "/v1/routes"
+
+	resolve := func(path string) string {
+		resp, err := http.Get(url + path)
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		require.Equal(t, http.StatusOK, resp.StatusCode, path)
+		ar := web.ActionResponse{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&ar))
+		return ar.Key
+	}
+
+	// Static segments take precedence over parameters
+	require.Equal(t, "/v1/routes/items/new", resolve("/items/new"))
+	require.Equal(t, "/v1/routes/items/:id", resolve("/items/42"))
+	require.Equal(t, "/v1/routes/items/:id/tags", resolve("/items/new/tags"))
+
+	// Catch-all parameter
+	require.Equal(t, "/v1/routes/files/*path", resolve("/files/a/b/c.txt"))
+
+	// Route flags are resolved per method (POST requires token with role)
+	td := &model.TokenData{SubjectId: "user", SubjectRole: 1}
+	token, err := server.TokenUtils().CreateToken(td)
+	require.NoError(t, err)
+	res := doRequest(t, http.MethodPost, url+"/items/42", map[string]string{"X-ACCESS-TOKEN": token})
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+}
+
+type guardedEndPoint struct{}
+
+func (e *guardedEndPoint) Path() string { return "/v1/guarded" }
+
+func (e *guardedEndPoint) RestEntries() []web.RestEntry {
+	return []web.RestEntry{
+		{Method: http.MethodGet, Path: "/items/public", Handler: e.ok, Skip: web.APIKEY | web.TOKEN},
+		{Method: http.MethodGet, Path: "/items/:id", Handler: e.ok},
+	}
+}
+
+func (e *guardedEndPoint) ok(c *gin.Context) {
+	c.JSON(http.StatusOK, web.NewActionResponse("", ""))
+}
+
+func TestRouteMetadataCaseSensitive(t *testing.T) {
+
+	server := web.NewWebServer().WithSecrets(secret, signing).AddRESTEndpoints(&guardedEndPoint{})
+	url := startTestServer(t, server) + "/v1/guarded"
+
+	// The public entry is matched exactly, a mixed case path is routed to the protected parameter entry
+	res := doRequest(t, http.MethodGet, url+"/items/public", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	res = doRequest(t, http.MethodGet, url+"/items/PUBLIC", nil)
+	require.Equal(t, http.StatusForbidden, res.StatusCode)
+	res = doRequest(t, http.MethodGet, url+"/items/Public", nil)
+	require.Equal(t, http.StatusForbidden, res.StatusCode)
+}
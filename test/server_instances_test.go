@@ -0,0 +1,120 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common-net/model"
+	"github.com/go-yaaf/yaaf-common-net/utils"
+	"github.com/go-yaaf/yaaf-common-net/web"
+)
+
+type secureEndPoint struct {
+	web.BaseEndPoint
+}
+
+func (e *secureEndPoint) Path() string { return "/v1/secure" }
+
+func (e *secureEndPoint) RestEntries() []web.RestEntry {
+	return []web.RestEntry{
+		{Method: http.MethodGet, Path: "/me", Handler: e.me},
+	}
+}
+
+func (e *secureEndPoint) me(c *gin.Context) {
+	td := e.GetTokenData(c)
+	if td == nil {
+		c.JSON(http.StatusOK, web.NewErrorResponse(fmt.Errorf("no token")))
+		return
+	}
+	c.JSON(http.StatusOK, web.NewActionResponse(td.SubjectId, td.AccountId))
+}
+
+// startTestServer starts the server on a free port and returns the base URL
+func startTestServer(t *testing.T, server *web.Server) string {
+	port := freePort(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = server.StartWithContext(ctx, port) }()
+	waitForServer(t, port)
+	return fmt.Sprintf("http://127.0.0.1:%d", port)
+}
+
+// doGet invokes GET request with the provided headers and returns the response status code
+func doGet(t *testing.T, url string, headers map[string]string) int {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestIndependentServers(t *testing.T) {
+
+	adminSecret := "admin secret string, must be at least 32 characters long"
+	adminSigning := "admin signing key, must be at least 32 characters long"
+
+	admin := web.NewWebServer().WithAppName("admin").WithSecrets(adminSecret, adminSigning).AddRESTEndpoints(&secureEndPoint{})
+	public := web.NewWebServer().WithAppName("public").WithSecrets(secret, signing).AddRESTEndpoints(&secureEndPoint{})
+
+	adminUrl := startTestServer(t, admin)
+	publicUrl := startTestServer(t, public)
+
+	td := &model.TokenData{AccountId: "account", SubjectId: "admin@email.com"}
+
+	adminKey, err := admin.TokenUtils().CreateApiKey("admin")
+	require.NoError(t, err)
+	adminToken, err := admin.TokenUtils().CreateToken(td)
+	require.NoError(t, err)
+
+	publicKey, err := public.TokenUtils().CreateApiKey("public")
+	require.NoError(t, err)
+	publicToken, err := public.TokenUtils().CreateToken(td)
+	require.NoError(t, err)
+
+	// Each server accepts its own credentials
+	require.Equal(t, http.StatusOK, doGet(t, adminUrl+"/v1/secure/me", map[string]string{"X-API-KEY": adminKey, "X-ACCESS-TOKEN": adminToken}))
+	require.Equal(t, http.StatusOK, doGet(t, publicUrl+"/v1/secure/me", map[string]string{"X-API-KEY": publicKey, "X-ACCESS-TOKEN": publicToken}))
+
+	// Credentials of one server are rejected by the other
+	require.Equal(t, http.StatusForbidden, doGet(t, publicUrl+"/v1/secure/me", map[string]string{"X-API-KEY": adminKey, "X-ACCESS-TOKEN": publicToken}))
+	require.Equal(t, http.StatusUnauthorized, doGet(t, publicUrl+"/v1/secure/me", map[string]string{"X-API-KEY": publicKey, "X-ACCESS-TOKEN": adminToken}))
+
+	// Missing credentials are rejected
+	require.Equal(t, http.StatusForbidden, doGet(t, adminUrl+"/v1/secure/me", nil))
+}
+
+func TestIndependentTokenUtils(t *testing.T) {
+
+	// Servers without secrets do not share the token utilities
+	first, second := web.NewWebServer(), web.NewWebServer()
+	require.NotSame(t, first.TokenUtils(), second.TokenUtils())
+	require.NotSame(t, utils.TokenUtils(), first.TokenUtils())
+
+	first.TokenUtils().WithIssuer("first")
+	token, err := first.TokenUtils().CreateToken(&model.TokenData{SubjectId: "user"})
+	require.NoError(t, err)
+	_, err = second.TokenUtils().WithIssuer("second").ParseToken(token)
+	require.Error(t, err)
+
+	// WithSecrets keeps the token utilities configuration
+	server := web.NewWebServer()
+	tu := server.TokenUtils().WithIssuer("heroes").WithTokenTTL(time.Minute, time.Hour)
+	server.WithSecrets(secret, signing)
+	require.Same(t, tu, server.TokenUtils())
+	require.Equal(t, time.Minute, server.TokenUtils().AccessTokenTTL())
+	token, err = server.TokenUtils().CreateToken(&model.TokenData{SubjectId: "user"})
+	require.NoError(t, err)
+	_, err = utils.NewTokenUtils().WithSecrets(secret, signing).WithIssuer("villains").ParseToken(token)
+	require.Error(t, err)
+}
@@ -0,0 +1,207 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common-net/web"
+)
+
+// region Test endpoints -----------------------------------------------------------------------------------------------
+
+type slowEndPoint struct {
+	web.BaseEndPoint
+	delay time.Duration
+}
+
+func (e *slowEndPoint) Path() string { return "/v1/test" }
+
+func (e *slowEndPoint) RestEntries() []web.RestEntry {
+	return []web.RestEntry{
+		{Method: http.MethodGet, Path: "/slow", Handler: e.slow, Skip: web.TOKEN},
+	}
+}
+
+func (e *slowEndPoint) slow(c *gin.Context) {
+	time.Sleep(e.delay)
+	c.JSON(http.StatusOK, web.NewActionResponse("slow", "done"))
+}
+
+type testWsEndPoint struct{}
+
+func (e *testWsEndPoint) Group() string            { return "test" }
+func (e *testWsEndPoint) Path() string             { return "/v1/ws/test" }
+func (e *testWsEndPoint) WSEntries() []web.WSEntry { return nil }
+
+// endregion
+
+// freePort returns an available TCP port on localhost
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = l.Close() }()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// waitForServer waits until the server accepts connections
+func waitForServer(t *testing.T, port int) {
+	for i := 0; i < 100; i++ {
+		if conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port)); err == nil {
+			_ = conn.Close()
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("server did not start on port %d", port)
+}
+
+func TestGracefulShutdown(t *testing.T) {
+
+	port := freePort(t)
+	server := web.NewWebServer().
+		WithSecrets(secret, signing).
+		WithShutdownTimeout(5 * time.Second).
+		AddRESTEndpoints(&slowEndPoint{delay: 500 * time.Millisecond}).
+		AddWebSocketEndpoints(&testWsEndPoint{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.StartWithContext(ctx, port) }()
+	waitForServer(t, port)
+
+	// Connect web socket client
+	wsConn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://127.0.0.1:%d/v1/ws/test", port), nil)
+	require.NoError(t, err)
+	defer func() { _ = wsConn.Close() }()
+
+	// Start in-flight request and cancel the context while it is processed
+	respCh := make(chan int, 1)
+	go func() {
+		resp, er := http.Get(fmt.Sprintf("http://127.0.0.1:%d/v1/test/slow", port))
+		if er != nil {
+			respCh <- 0
+			return
+		}
+		_ = resp.Body.Close()
+		respCh <- resp.StatusCode
+	}()
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	require.Equal(t, http.StatusOK, <-respCh, "in-flight request should be completed")
+	require.NoError(t, <-done)
+
+	// Web socket client should receive close frame
+	_ = wsConn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = wsConn.ReadMessage()
+	require.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "expected close frame, got: %v", err)
+
+	// New connections are refused
+	_, err = http.Get(fmt.Sprintf("http://127.0.0.1:%d/v1/test/slow", port))
+	require.Error(t, err)
+}
+
+func TestStartWaitsForShutdown(t *testing.T) {
+
+	port := freePort(t)
+	server := web.NewWebServer().
+		WithSecrets(secret, signing).
+		AddRESTEndpoints(&slowEndPoint{delay: 500 * time.Millisecond}).
+		AddWebSocketEndpoints(&testWsEndPoint{})
+
+	started := make(chan error, 1)
+	go func() { started <- server.Start(port) }()
+	waitForServer(t, port)
+
+	wsConn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://127.0.0.1:%d/v1/ws/test", port), nil)
+	require.NoError(t, err)
+	defer func() { _ = wsConn.Close() }()
+	registry := server.WebSocketRegistry("test")
+	require.Eventually(t, func() bool { return registry.ConnectedClients() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// In-flight request while Shutdown is called from another goroutine
+	go func() {
+		if resp, er := http.Get(fmt.Sprintf("http://127.0.0.1:%d/v1/test/slow", port)); er == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+	time.Sleep(100 * time.Millisecond)
+	shutdown := make(chan error, 1)
+	start := time.Now()
+	go func() { shutdown <- server.Shutdown(context.Background()) }()
+
+	// Start returns only after the in-flight request is drained and the web socket clients are closed
+	require.NoError(t, <-started)
+	require.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+	require.Equal(t, 0, registry.ConnectedClients())
+	require.NoError(t, <-shutdown)
+}
+
+func TestShutdownSlowWsClients(t *testing.T) {
+
+	port := freePort(t)
+	server := web.NewWebServer().AddWebSocketEndpoints(&testWsEndPoint{})
+
+	go func() { _ = server.StartWithContext(context.Background(), port) }()
+	waitForServer(t, port)
+
+	// The raw clients never read, so the server send queues can not be flushed
+	for i := 0; i < 20; i++ {
+		wsConn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://127.0.0.1:%d/v1/ws/test", port), nil)
+		require.NoError(t, err)
+		defer func() { _ = wsConn.Close() }()
+	}
+	registry := server.WebSocketRegistry("test")
+	require.Eventually(t, func() bool { return registry.ConnectedClients() == 20 }, 5*time.Second, 10*time.Millisecond)
+
+	payload := []byte(strings.Repeat("x", 4*1024*1024))
+	for i := 0; i < 5; i++ {
+		registry.Broadcast(payload)
+	}
+
+	// The clients are closed in parallel and the shutdown is limited by the context deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_ = server.Shutdown(ctx)
+	require.Less(t, time.Since(start), 2*time.Second)
+	require.Equal(t, 0, registry.ConnectedClients())
+}
+
+// slowCloseClient is a Web-socket client that takes time to close (e.g. waiting for the close frame of a gone peer)
+type slowCloseClient struct {
+	id string
+}
+
+func (c *slowCloseClient) ID() string                  { return c.id }
+func (c *slowCloseClient) Send(m web.IWSMessage) error { return nil }
+func (c *slowCloseClient) SendRaw(m []byte) error      { return nil }
+func (c *slowCloseClient) Close() error {
+	time.Sleep(2 * time.Second)
+	return nil
+}
+
+func TestUnregisterSlowWsClient(t *testing.T) {
+
+	registry := web.NewClientRegistry("test")
+	registry.RegisterClient(&slowCloseClient{id: "slow"})
+
+	// The registry is not locked while the unregistered client is closed
+	go registry.UnregisterClient(&slowCloseClient{id: "slow"})
+	require.Eventually(t, func() bool { return registry.ConnectedClients() == 0 }, time.Second, time.Millisecond)
+
+	start := time.Now()
+	registry.RegisterClient(&slowCloseClient{id: "other"})
+	registry.Broadcast([]byte("hello"))
+	require.NotNil(t, registry.Client("other"))
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+}
@@ -0,0 +1,140 @@
+package test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common-net/web"
+)
+
+type certEndPoint struct {
+	web.BaseEndPoint
+}
+
+func (e *certEndPoint) Path() string { return "/v1/cert" }
+
+func (e *certEndPoint) RestEntries() []web.RestEntry {
+	return []web.RestEntry{
+		{Method: http.MethodGet, Path: "/subject", Handler: e.subject, Skip: web.TOKEN},
+	}
+}
+
+func (e *certEndPoint) subject(c *gin.Context) {
+	c.JSON(http.StatusOK, web.NewActionResponse("subject", e.GetClientSubject(c)))
+}
+
+// issueCert creates a certificate signed by the parent (self-signed if parent is nil)
+func issueCert(t *testing.T, cn string, parent *tls.Certificate, isCA bool) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn, Organization: []string{"yaaf"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signerCert, signerKey := tmpl, any(key)
+	if parent != nil {
+		signerCert, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signerCert, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestMutualTLS(t *testing.T) {
+
+	ca := issueCert(t, "test-ca", nil, true)
+	serverCert := issueCert(t, "127.0.0.1", &ca, false)
+	clientCert := issueCert(t, "internal-service", &ca, false)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	port := freePort(t)
+	server := web.NewWebServer().
+		WithSecrets(secret, signing).
+		WithTLSConfig(&tls.Config{Certificates: []tls.Certificate{serverCert}}).
+		WithClientCAPool(pool, true).
+		AddRESTEndpoints(&certEndPoint{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = server.StartWithContext(ctx, port) }()
+	waitForServer(t, port)
+
+	url := fmt.Sprintf("https://127.0.0.1:%d/v1/cert/subject", port)
+
+	// Client with certificate
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      pool,
+		Certificates: []tls.Certificate{clientCert},
+	}}}
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	ar := web.ActionResponse{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&ar))
+	require.Equal(t, "CN=internal-service,O=yaaf", ar.Data)
+
+	// Client without certificate is rejected during handshake
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	_, err = anonymous.Get(url)
+	require.Error(t, err)
+}
+
+func TestWebSocketTLS(t *testing.T) {
+
+	ca := issueCert(t, "test-ca", nil, true)
+	serverCert := issueCert(t, "127.0.0.1", &ca, false)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	port := freePort(t)
+	server := web.NewWebServer().
+		WithTLSConfig(&tls.Config{Certificates: []tls.Certificate{serverCert}}).
+		AddWebSocketEndpoints(&testWsEndPoint{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = server.StartWithContext(ctx, port) }()
+	waitForServer(t, port)
+
+	// The web socket connection over TLS is registered
+	dialer := websocket.Dialer{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	conn, _, err := dialer.Dial(fmt.Sprintf("wss://127.0.0.1:%d/v1/ws/test", port), nil)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	registry := server.WebSocketRegistry("test")
+	require.Eventually(t, func() bool { return registry.ConnectedClients() == 1 }, 5*time.Second, 10*time.Millisecond)
+}
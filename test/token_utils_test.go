@@ -1,11 +1,22 @@
 package test
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"github.com/go-yaaf/yaaf-common-net/model"
 	"github.com/go-yaaf/yaaf-common-net/utils"
+	"github.com/go-yaaf/yaaf-common-net/web"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/require"
 )
 
@@ -56,5 +67,205 @@ func TestApiKey(t *testing.T) {
 	actual, er := tu.ParseApiKey(apiKey)
 
 	require.Nil(t, er, "actual is nil")
-	require.Equal(t, actual, appName, "incompatible App Name")
+	require.Equal(t, actual.AppName, appName, "incompatible App Name")
+}
+
+func TestSigningKeyRotation(t *testing.T) {
+
+	tu := utils.NewTokenUtils().WithSecrets(secret, signing)
+	td := &model.TokenData{AccountId: "accountId", SubjectId: "subject@email.com"}
+
+	legacy, err := tu.CreateToken(td)
+	require.NoError(t, err)
+
+	// Rotate the key, tokens signed by the previous key are still valid
+	require.NoError(t, tu.RotateSigningKey("k1", "first rotated signing key, at least 32 characters", time.Hour))
+	first, err := tu.CreateToken(td)
+	require.NoError(t, err)
+	_, err = tu.ParseToken(legacy)
+	require.NoError(t, err)
+
+	// Rotate again without grace period for the previous key, then remove it
+	require.NoError(t, tu.RotateSigningKey("k2", "second rotated signing key, at least 32 characters", 0))
+	_, err = tu.ParseToken(first)
+	require.NoError(t, err)
+	require.NoError(t, tu.RemoveSigningKey("k1"))
+	_, err = tu.ParseToken(first)
+	require.Error(t, err)
+	require.Error(t, tu.RemoveSigningKey("k2"))
+
+	// Verification only key of another instance
+	other := utils.NewTokenUtils()
+	require.NoError(t, other.RotateSigningKey("k3", "third rotated signing key, at least 32 characters", 0))
+	third, err := other.CreateToken(td)
+	require.NoError(t, err)
+	_, err = tu.ParseToken(third)
+	require.Error(t, err)
+	require.NoError(t, tu.AddVerificationKey("k3", "third rotated signing key, at least 32 characters", time.Now().Add(time.Hour)))
+	_, err = tu.ParseToken(third)
+	require.NoError(t, err)
+
+	// Replacing the secrets keeps the verification only keys
+	tu.WithSecrets(secret, signing)
+	_, err = tu.ParseToken(third)
+	require.NoError(t, err)
+	_, err = tu.ParseToken(first)
+	require.Error(t, err)
+}
+
+func TestApiSecretRotation(t *testing.T) {
+
+	tu := utils.NewTokenUtils().WithSecrets(secret, signing)
+	first, err := tu.CreateApiKey("first-app")
+	require.NoError(t, err)
+
+	require.NoError(t, tu.RotateApiSecret("v2", "rotated api secret string, at least 32 characters"))
+	rotated, err := tu.CreateApiKey("new-app")
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(rotated, "ak1.v2."))
+
+	// Both versions are valid
+	info, err := tu.ParseApiKey(first)
+	require.NoError(t, err)
+	require.Equal(t, "first-app", info.AppName)
+	info, err = tu.ParseApiKey(rotated)
+	require.NoError(t, err)
+	require.Equal(t, "new-app", info.AppName)
+
+	// Removed version is rejected
+	require.NoError(t, tu.RemoveApiSecret(""))
+	_, err = tu.ParseApiKey(first)
+	require.Error(t, err)
+}
+
+// legacyApiKey creates API key in the legacy format: hex(IV + AES-CTR(application name))
+func legacyApiKey(t *testing.T, apiSecret, appName string) string {
+	block, err := aes.NewCipher([]byte(apiSecret[:32]))
+	require.NoError(t, err)
+	data := make([]byte, aes.BlockSize+len(appName))
+	_, err = rand.Read(data[:aes.BlockSize])
+	require.NoError(t, err)
+	cipher.NewCTR(block, data[:aes.BlockSize]).XORKeyStream(data[aes.BlockSize:], []byte(appName))
+	return hex.EncodeToString(data)
+}
+
+func TestApiKeyFormat(t *testing.T) {
+
+	tu := utils.NewTokenUtils().WithSecrets(secret, signing)
+
+	// Scopes and expiration
+	apiKey, err := tu.CreateScopedApiKey("heroes-app", time.Hour, "heroes:read", "villains:*")
+	require.NoError(t, err)
+	info, err := tu.ParseApiKey(apiKey)
+	require.NoError(t, err)
+	require.Equal(t, "heroes-app", info.AppName)
+	require.NotEmpty(t, info.KeyId)
+	require.Equal(t, []string{"heroes:read", "villains:*"}, info.Scopes)
+	require.InDelta(t, time.Now().Add(time.Hour).UnixMilli(), info.ExpiresAt, 1000)
+
+	// Tampered key is rejected
+	tampered := []byte(apiKey)
+	tampered[len(tampered)-5] ^= 1
+	_, err = tu.ParseApiKey(string(tampered))
+	require.Error(t, err)
+
+	// Expired key is rejected
+	expired, err := tu.WithClockSkew(0).CreateScopedApiKey("heroes-app", time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+	_, err = tu.ParseApiKey(expired)
+	require.Error(t, err)
+
+	// Legacy keys are rejected unless enabled
+	legacy := legacyApiKey(t, secret, "legacy-app")
+	_, err = tu.ParseApiKey(legacy)
+	require.ErrorContains(t, err, "WithLegacyApiKeys")
+
+	info, err = tu.WithLegacyApiKeys(true).ParseApiKey(legacy)
+	require.NoError(t, err)
+	require.Equal(t, "legacy-app", info.AppName)
+	require.Empty(t, info.KeyId)
+}
+
+func TestStandardClaims(t *testing.T) {
+
+	issuer := utils.NewTokenUtils().WithSecrets(secret, signing).WithIssuer("identity").WithAudience("heroes", "villains")
+	td := &model.TokenData{AccountId: "accountId", SubjectId: "subject@email.com", ExpiresIn: time.Now().Add(time.Hour).UnixMilli()}
+
+	token, err := issuer.CreateToken(td)
+	require.NoError(t, err)
+
+	// The exp claim is synced with ExpiresIn
+	claims := &utils.TokenClaims{}
+	_, _, err = jwt.NewParser().ParseUnverified(token, claims)
+	require.NoError(t, err)
+	require.Equal(t, td.ExpiresIn/1000, claims.ExpiresAt.Unix())
+	require.Equal(t, "identity", claims.Issuer)
+	require.Equal(t, jwt.ClaimStrings{"heroes", "villains"}, claims.Audience)
+
+	// Accepted by the audience service trusting the issuer
+	heroes := utils.NewTokenUtils().WithSecrets(secret, signing).WithIssuer("heroes", "identity").WithAudience("heroes")
+	_, err = heroes.ParseToken(token)
+	require.NoError(t, err)
+
+	// Rejected for other audience or untrusted issuer
+	_, err = utils.NewTokenUtils().WithSecrets(secret, signing).WithAudience("admin").ParseToken(token)
+	require.ErrorIs(t, err, jwt.ErrTokenInvalidAudience)
+	_, err = utils.NewTokenUtils().WithSecrets(secret, signing).WithIssuer("heroes").ParseToken(token)
+	require.ErrorIs(t, err, jwt.ErrTokenInvalidIssuer)
+
+	// Expired token is rejected, unless within the allowed clock skew
+	td.ExpiresIn = time.Now().Add(-10 * time.Second).UnixMilli()
+	expired, err := issuer.CreateToken(td)
+	require.NoError(t, err)
+	_, err = issuer.ParseToken(expired)
+	require.NoError(t, err)
+	_, err = issuer.WithClockSkew(0).ParseToken(expired)
+	require.ErrorIs(t, err, jwt.ErrTokenExpired)
+}
+
+type planClaim struct {
+	Name  string `json:"name"`
+	Seats int    `json:"seats"`
+}
+
+func TestCustomClaims(t *testing.T) {
+
+	td := (&model.TokenData{AccountId: "accountId", SubjectId: "subject@email.com"}).
+		WithClaim("locale", "he-IL").
+		WithClaim("scopes", []string{"heroes:read", "heroes:write"}).
+		WithClaim("plan", planClaim{Name: "pro", Seats: 10}).
+		WithClaim("quota", 1000)
+
+	// Round trip the claims via the access token, and the endpoint token data
+	token, err := utils.TokenUtils().CreateToken(td)
+	require.NoError(t, err)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Request.Header.Set("X-ACCESS-TOKEN", token)
+	actual := (&web.BaseEndPoint{}).GetTokenData(c)
+	require.NotNil(t, actual)
+
+	locale, ok := model.Claim[string](actual, "locale")
+	require.True(t, ok)
+	require.Equal(t, "he-IL", locale)
+
+	scopes, ok := model.Claim[[]string](actual, "scopes")
+	require.True(t, ok)
+	require.Equal(t, []string{"heroes:read", "heroes:write"}, scopes)
+
+	plan, ok := model.Claim[planClaim](actual, "plan")
+	require.True(t, ok)
+	require.Equal(t, planClaim{Name: "pro", Seats: 10}, plan)
+
+	quota, ok := model.Claim[int](actual, "quota")
+	require.True(t, ok)
+	require.Equal(t, 1000, quota)
+
+	// Missing claim, or claim of incompatible type
+	_, ok = model.Claim[string](actual, "missing")
+	require.False(t, ok)
+	_, ok = model.Claim[int](actual, "locale")
+	require.False(t, ok)
 }
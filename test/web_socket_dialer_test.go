@@ -0,0 +1,87 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common-net/web"
+)
+
+const chatOpCode = 100
+
+type chatMessage struct {
+	web.WSMessageHeader
+	Text string
+}
+
+func (m *chatMessage) Payload() any { return m.Text }
+
+func init() {
+	web.AddMessageFactory(chatOpCode, func() web.IWSMessage { return &chatMessage{} })
+}
+
+// echoServer upgrades the connection and sends back every received message
+func echoServer(t *testing.T) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/ws/echo" {
+			http.NotFound(w, r)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		for {
+			mt, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err = conn.WriteMessage(mt, data); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDialWsClient(t *testing.T) {
+
+	server := echoServer(t)
+
+	received := make(chan string, 1)
+	entry := web.WSEntry{OpCode: chatOpCode, Handler: func(m web.IWSMessage, rw web.IWSClient) error {
+		received <- m.Payload().(string)
+		return nil
+	}}
+
+	params := web.WSConnectParams{Host: strings.TrimPrefix(server.URL, "http://"), Path: "/v1/ws/echo"}
+	client, err := web.DialWsClient(context.Background(), params, entry)
+	require.NoError(t, err)
+	defer func() { _ = client.Close() }()
+
+	msg := &chatMessage{WSMessageHeader: web.WSMessageHeader{OpCode: chatOpCode, MessageId: 1}, Text: "hello"}
+	require.NoError(t, client.Send(msg))
+
+	select {
+	case text := <-received:
+		require.Equal(t, "hello", text)
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not dispatched to the handler")
+	}
+
+	// Handshake failure and invalid URL
+	_, err = web.DialWsClient(context.Background(), web.WSConnectParams{Url: "ws" + strings.TrimPrefix(server.URL, "http") + "/unknown"})
+	require.ErrorContains(t, err, "404")
+	_, err = web.DialWsClient(context.Background(), web.WSConnectParams{Url: server.URL})
+	require.ErrorContains(t, err, "scheme")
+}
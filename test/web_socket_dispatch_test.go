@@ -0,0 +1,157 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common-net/web"
+)
+
+const (
+	failOpCode  = 102
+	panicOpCode = 103
+)
+
+type dispatchEndPoint struct{}
+
+func (e *dispatchEndPoint) Group() string { return "dispatch" }
+func (e *dispatchEndPoint) Path() string  { return "/v1/ws/dispatch" }
+
+func (e *dispatchEndPoint) WSEntries() []web.WSEntry {
+	return []web.WSEntry{
+		{OpCode: chatOpCode, Handler: e.echo},
+		{OpCode: failOpCode, Handler: func(m web.IWSMessage, rw web.IWSClient) error { return fmt.Errorf("invalid hero") }},
+		{OpCode: panicOpCode, Handler: func(m web.IWSMessage, rw web.IWSClient) error { panic("boom") }},
+	}
+}
+
+func (e *dispatchEndPoint) Fallback(m web.IWSMessage, rw web.IWSClient) error {
+	return rw.Send(&chatMessage{WSMessageHeader: web.WSMessageHeader{OpCode: chatOpCode}, Text: fmt.Sprintf("unknown:%d", m.MessageCode())})
+}
+
+func (e *dispatchEndPoint) DispatchMode() web.WSDispatchMode { return web.WSDispatchSequential }
+
+func (e *dispatchEndPoint) echo(m web.IWSMessage, rw web.IWSClient) error {
+	return rw.Send(m)
+}
+
+func TestWsDispatch(t *testing.T) {
+
+	server := web.NewWebServer().AddWebSocketEndpoints(&dispatchEndPoint{})
+	url := startTestServer(t, server)
+
+	received := make(chan string, 100)
+	push := func(m web.IWSMessage, rw web.IWSClient) error {
+		received <- fmt.Sprintf("%d:%d:%v", m.MessageCode(), m.MessageID(), m.Payload())
+		return nil
+	}
+	params := web.WSConnectParams{Url: "ws" + strings.TrimPrefix(url, "http") + "/v1/ws/dispatch", Dispatch: web.WSDispatchSequential}
+	client, err := web.DialWsClient(context.Background(), params,
+		web.WSEntry{OpCode: chatOpCode, Handler: push},
+		web.WSEntry{OpCode: web.WsErrorOpCode, Handler: push},
+	)
+	require.NoError(t, err)
+	defer func() { _ = client.Close() }()
+
+	send := func(opCode int, id uint64, text string) {
+		require.NoError(t, client.Send(&chatMessage{WSMessageHeader: web.WSMessageHeader{OpCode: opCode, MessageId: id}, Text: text}))
+	}
+	expect := func(expected string) {
+		select {
+		case actual := <-received:
+			require.Equal(t, expected, actual)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message %s was not received", expected)
+		}
+	}
+
+	// Sequential dispatch keeps the order, and the read loop continues after the first message
+	for i := 1; i <= 20; i++ {
+		send(chatOpCode, uint64(i), fmt.Sprintf("m%d", i))
+	}
+	for i := 1; i <= 20; i++ {
+		expect(fmt.Sprintf("%d:%d:m%d", chatOpCode, i, i))
+	}
+
+	// Handler error and panic are sent back as error messages
+	send(failOpCode, 7, "")
+	expect(fmt.Sprintf("%d:7:invalid hero", web.WsErrorOpCode))
+	send(panicOpCode, 8, "")
+	expect(fmt.Sprintf("%d:8:internal error handling message op-code: %d", web.WsErrorOpCode, panicOpCode))
+
+	// Fallback handler of unknown op-code
+	send(200, 9, "")
+	expect(fmt.Sprintf("%d:0:unknown:200", chatOpCode))
+
+	// The connection survives the panic
+	send(chatOpCode, 10, "alive")
+	expect(fmt.Sprintf("%d:10:alive", chatOpCode))
+}
+
+const typedOpCode = 104
+
+// heroMessage and villainMessage use the same op-code on different endpoints
+type heroMessage struct {
+	web.WSMessageHeader
+	Hero string
+}
+
+func (m *heroMessage) Payload() any { return m.Hero }
+
+type villainMessage struct {
+	web.WSMessageHeader
+	Villain string
+}
+
+func (m *villainMessage) Payload() any { return m.Villain }
+
+// typedWsEndPoint creates the messages of the op-code by its own message factory
+type typedWsEndPoint struct {
+	path    string
+	message web.MessageFactoryFunc
+}
+
+func (e *typedWsEndPoint) Group() string { return e.path }
+func (e *typedWsEndPoint) Path() string  { return e.path }
+
+func (e *typedWsEndPoint) WSEntries() []web.WSEntry {
+	return []web.WSEntry{{OpCode: typedOpCode, Message: e.message, Handler: e.reply}}
+}
+
+func (e *typedWsEndPoint) reply(m web.IWSMessage, rw web.IWSClient) error {
+	return rw.Send(&chatMessage{WSMessageHeader: web.WSMessageHeader{OpCode: chatOpCode}, Text: fmt.Sprintf("%T", m)})
+}
+
+func TestWsMessageFactoryPerEndpoint(t *testing.T) {
+
+	heroes := &typedWsEndPoint{path: "/v1/ws/heroes", message: func() web.IWSMessage { return &heroMessage{} }}
+	villains := &typedWsEndPoint{path: "/v1/ws/villains", message: func() web.IWSMessage { return &villainMessage{} }}
+	url := "ws" + strings.TrimPrefix(startTestServer(t, web.NewWebServer().AddWebSocketEndpoints(heroes, villains)), "http")
+
+	// Each endpoint decodes the op-code by its own message type
+	for path, expected := range map[string]string{heroes.path: "*test.heroMessage", villains.path: "*test.villainMessage"} {
+		received := make(chan string, 1)
+		client, err := web.DialWsClient(context.Background(), web.WSConnectParams{Url: url + path}, web.WSEntry{OpCode: chatOpCode,
+			Handler: func(m web.IWSMessage, rw web.IWSClient) error {
+				received <- fmt.Sprintf("%v", m.Payload())
+				return nil
+			}})
+		require.NoError(t, err)
+		require.NoError(t, client.Send(&heroMessage{WSMessageHeader: web.WSMessageHeader{OpCode: typedOpCode}}))
+		select {
+		case actual := <-received:
+			require.Equal(t, expected, actual)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("reply of %s was not received", path)
+		}
+		_ = client.Close()
+	}
+
+	// The message factories of the entries are not registered globally
+	require.Nil(t, web.GetMessageFactoryFunc(typedOpCode))
+}
@@ -0,0 +1,95 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common-net/web"
+)
+
+func TestWsKeepAlive(t *testing.T) {
+
+	// The echo server replies pong to ping frames
+	server := echoServer(t)
+	latencies := make(chan int64, 10)
+	params := web.WSConnectParams{
+		Url: "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/ws/echo",
+		KeepAlive: web.WSKeepAlive{PingInterval: 20 * time.Millisecond, OnPong: func(sessionId, pong string, latencyMs int64) {
+			select {
+			case latencies <- latencyMs:
+			default:
+			}
+		}},
+	}
+	client, err := web.DialWsClient(context.Background(), params)
+	require.NoError(t, err)
+	defer func() { _ = client.Close() }()
+
+	select {
+	case latency := <-latencies:
+		require.GreaterOrEqual(t, latency, int64(0))
+		require.Greater(t, client.(*web.WSClient).Latency(), time.Duration(0))
+	case <-time.After(5 * time.Second):
+		t.Fatal("pong was not received")
+	}
+}
+
+// silentServer upgrades the connection and never reads from it
+func silentServer(t *testing.T) *httptest.Server {
+	release := make(chan struct{})
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if conn, err := upgrader.Upgrade(w, r, nil); err == nil {
+			<-release
+			_ = conn.Close()
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+	return server
+}
+
+func TestWsDeadPeer(t *testing.T) {
+
+	// The silent server never reads, so ping frames are not answered
+	server := silentServer(t)
+
+	disconnected := make(chan struct{})
+	params := web.WSConnectParams{
+		Url:          "ws" + strings.TrimPrefix(server.URL, "http"),
+		KeepAlive:    web.WSKeepAlive{PingInterval: 20 * time.Millisecond, PongTimeout: 60 * time.Millisecond},
+		OnDisconnect: func(web.IWSClient) { close(disconnected) },
+	}
+	_, err := web.DialWsClient(context.Background(), params)
+	require.NoError(t, err)
+
+	select {
+	case <-disconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("dead peer was not detected")
+	}
+}
+
+func TestServerWsKeepAlive(t *testing.T) {
+
+	server := web.NewWebServer().
+		WithWebSocketKeepAlive(web.WSKeepAlive{PingInterval: 20 * time.Millisecond, PongTimeout: 60 * time.Millisecond}).
+		AddWebSocketEndpoints(&testWsEndPoint{})
+	url := startTestServer(t, server)
+
+	// The raw client never reads, so the server pings are not answered and the client is unregistered
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http")+"/v1/ws/test", nil)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	registry := server.WebSocketRegistry("test")
+	require.Eventually(t, func() bool { return registry.ConnectedClients() == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return registry.ConnectedClients() == 0 }, 5*time.Second, 10*time.Millisecond)
+}
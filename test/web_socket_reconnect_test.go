@@ -0,0 +1,160 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common-net/web"
+)
+
+const subscribeOpCode = 101
+
+// flakyServer is an echo server that can drop the connections and reject new ones
+type flakyServer struct {
+	*httptest.Server
+	down          atomic.Bool
+	subscriptions atomic.Int32
+	conns         []*websocket.Conn
+	mu            sync.Mutex
+}
+
+func newFlakyServer(t *testing.T) *flakyServer {
+	fs := &flakyServer{}
+	upgrader := websocket.Upgrader{}
+	fs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fs.down.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		fs.mu.Lock()
+		fs.conns = append(fs.conns, conn)
+		fs.mu.Unlock()
+
+		for {
+			mt, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if strings.Contains(string(data), `"OpCode":101`) {
+				fs.subscriptions.Add(1)
+				continue
+			}
+			if err = conn.WriteMessage(mt, data); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(fs.Close)
+	return fs
+}
+
+// drop closes all the connections, new connections are rejected while down
+func (fs *flakyServer) drop(down bool) {
+	fs.down.Store(down)
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	for _, conn := range fs.conns {
+		_ = conn.Close()
+	}
+	fs.conns = nil
+}
+
+func TestReconnectingWsClient(t *testing.T) {
+
+	server := newFlakyServer(t)
+
+	received := make(chan string, 2000)
+	states := make(chan web.WSConnectionState, 100)
+	entry := web.WSEntry{OpCode: chatOpCode, Handler: func(m web.IWSMessage, rw web.IWSClient) error {
+		received <- m.Payload().(string)
+		return nil
+	}}
+	cfg := web.WSReconnectConfig{
+		InitialDelay: 10 * time.Millisecond,
+		MaxDelay:     50 * time.Millisecond,
+		Subscriptions: func() []web.IWSMessage {
+			return []web.IWSMessage{&chatMessage{WSMessageHeader: web.WSMessageHeader{OpCode: subscribeOpCode}}}
+		},
+		OnStateChanged: func(_ web.IWSClient, state web.WSConnectionState, _ error) { states <- state },
+	}
+
+	params := web.WSConnectParams{Url: "ws" + strings.TrimPrefix(server.URL, "http")}
+	client, err := web.DialReconnectingWsClient(context.Background(), params, cfg, entry)
+	require.NoError(t, err)
+
+	waitState := func(expected web.WSConnectionState) {
+		for {
+			select {
+			case state := <-states:
+				if state == expected {
+					return
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("state %s was not reached", expected)
+			}
+		}
+	}
+	waitMessage := func(expected string) {
+		select {
+		case text := <-received:
+			require.Equal(t, expected, text)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("message %s was not received", expected)
+		}
+	}
+	chat := func(text string) web.IWSMessage {
+		return &chatMessage{WSMessageHeader: web.WSMessageHeader{OpCode: chatOpCode}, Text: text}
+	}
+
+	waitState(web.WSConnected)
+	require.NoError(t, client.Send(chat("first")))
+	waitMessage("first")
+
+	// Messages sent while the server is down are buffered and sent after reconnecting
+	server.drop(true)
+	waitState(web.WSDisconnected)
+	require.NoError(t, client.Send(chat("buffered")))
+	server.down.Store(false)
+	waitState(web.WSConnected)
+	waitMessage("buffered")
+	require.Equal(t, int32(2), server.subscriptions.Load())
+
+	// The whole outbound queue is flushed to the new connection (larger than the connection default send queue)
+	server.drop(true)
+	waitState(web.WSDisconnected)
+	expected := make([]string, 1000)
+	for i := range expected {
+		expected[i] = fmt.Sprintf("m%d", i)
+		require.NoError(t, client.Send(chat(expected[i])))
+	}
+	server.down.Store(false)
+	waitState(web.WSConnected)
+	actual := make([]string, 0, len(expected))
+	for range expected {
+		select {
+		case text := <-received:
+			actual = append(actual, text)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d of %d buffered messages were received", len(actual), len(expected))
+		}
+	}
+	require.ElementsMatch(t, expected, actual)
+
+	require.NoError(t, client.Close())
+	waitState(web.WSClosed)
+	require.Error(t, client.Send(chat("closed")))
+}
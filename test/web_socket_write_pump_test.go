@@ -0,0 +1,96 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-yaaf/yaaf-common-net/web"
+)
+
+func TestWsConcurrentSend(t *testing.T) {
+
+	server := echoServer(t)
+
+	var received atomic.Int32
+	entry := web.WSEntry{OpCode: chatOpCode, Handler: func(m web.IWSMessage, rw web.IWSClient) error {
+		received.Add(1)
+		return nil
+	}}
+	params := web.WSConnectParams{
+		Url:       "ws" + strings.TrimPrefix(server.URL, "http") + "/v1/ws/echo",
+		SendQueue: web.WSSendQueue{Size: 1000},
+	}
+	client, err := web.DialWsClient(context.Background(), params, entry)
+	require.NoError(t, err)
+	defer func() { _ = client.Close() }()
+
+	// Concurrent senders are serialized by the write pump
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				assert.NoError(t, client.Send(&chatMessage{WSMessageHeader: web.WSMessageHeader{OpCode: chatOpCode}, Text: "hello"}))
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Eventually(t, func() bool { return received.Load() == 500 }, 5*time.Second, 10*time.Millisecond)
+	stats := client.(*web.WSClient).Stats()
+	require.Equal(t, uint64(500), stats.Sent)
+	require.Equal(t, uint64(0), stats.Dropped)
+	require.Equal(t, 1000, stats.QueueSize)
+}
+
+func TestWsSlowConsumer(t *testing.T) {
+
+	// Large messages to a silent server fill the socket buffers, so the write pump blocks and the queue fills up
+	payload := []byte(strings.Repeat("x", 1024*1024))
+	dial := func(policy web.WSQueuePolicy, onDisconnect web.DisconnectedCb) *web.WSClient {
+		params := web.WSConnectParams{
+			Url:          "ws" + strings.TrimPrefix(silentServer(t).URL, "http"),
+			SendQueue:    web.WSSendQueue{Size: 2, Policy: policy},
+			OnDisconnect: onDisconnect,
+		}
+		client, err := web.DialWsClient(context.Background(), params)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = client.Close() })
+		return client.(*web.WSClient)
+	}
+	fill := func(client *web.WSClient) (err error) {
+		for i := 0; i < 100 && err == nil; i++ {
+			err = client.SendRaw(payload)
+		}
+		return err
+	}
+
+	// Drop oldest: never fails, the queue is bounded
+	oldest := dial(web.WSDropOldest, nil)
+	require.NoError(t, fill(oldest))
+	require.Greater(t, oldest.Stats().Dropped, uint64(0))
+	require.LessOrEqual(t, oldest.Stats().QueueDepth, 2)
+
+	// Drop newest: fails when the queue is full
+	newest := dial(web.WSDropNewest, nil)
+	require.ErrorContains(t, fill(newest), "message dropped")
+	require.Equal(t, uint64(1), newest.Stats().Dropped)
+
+	// Disconnect: the slow client is disconnected
+	disconnected := make(chan struct{})
+	slow := dial(web.WSDisconnect, func(web.IWSClient) { close(disconnected) })
+	require.ErrorContains(t, fill(slow), "disconnected")
+	select {
+	case <-disconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow client was not disconnected")
+	}
+}
@@ -0,0 +1,281 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// region JSON Web Key Set ---------------------------------------------------------------------------------------------
+
+// JSONWebKey is a public key in JWK format (RFC 7517)
+type JSONWebKey struct {
+	Kty string `json:"kty"`           // Key type: RSA | EC | OKP
+	Kid string `json:"kid"`           // Key ID (the kid header of the signed tokens)
+	Use string `json:"use,omitempty"` // Key usage: sig
+	Alg string `json:"alg,omitempty"` // Signing algorithm: RS256 | ES256 | ES384 | ES512 | EdDSA
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // Curve: P-256 | P-384 | P-521 | Ed25519
+	X   string `json:"x,omitempty"`   // EC or OKP x coordinate
+	Y   string `json:"y,omitempty"`   // EC y coordinate
+}
+
+// JSONWebKeySet is a set of public keys in JWKS format
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS returns the public keys of the asymmetric signing keys (to be published for the token verifying services)
+// Only the keys signing the tokens of this instance are published (including rotated keys in their grace period),
+// verification only keys of other issuers (see AddJWKS and AddVerificationKeyPEM) are not published
+func (t *TokenUtilsStruct) JWKS() *JSONWebKeySet {
+	set := &JSONWebKeySet{Keys: make([]JSONWebKey, 0)}
+	for _, key := range t.keys.list() {
+		if key.verifyOnly {
+			continue
+		}
+		if jwk, err := newJSONWebKey(key.id, key.verify); err == nil {
+			set.Keys = append(set.Keys, *jwk)
+		}
+	}
+	return set
+}
+
+// AddJWKS adds the public keys of the key set for verification only (e.g. keys published by the token issuer)
+// Keys without kid, or with the kid of a signing key of this instance, are rejected
+func (t *TokenUtilsStruct) AddJWKS(set *JSONWebKeySet) error {
+	for _, jwk := range set.Keys {
+		if len(jwk.Use) > 0 && jwk.Use != "sig" {
+			continue
+		}
+		pub, err := jwk.publicKey()
+		if err != nil {
+			return fmt.Errorf("invalid key %s: %s", jwk.Kid, err.Error())
+		}
+		method, err := signingMethod(pub)
+		if err != nil {
+			return fmt.Errorf("invalid key %s: %s", jwk.Kid, err.Error())
+		}
+		if err = t.keys.add(&signingKey{id: jwk.Kid, method: method, verify: pub, verifyOnly: true}, false, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadJWKSFile adds the public keys of the key set file for verification only
+func (t *TokenUtilsStruct) LoadJWKSFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	set := &JSONWebKeySet{}
+	if err = json.Unmarshal(data, set); err != nil {
+		return fmt.Errorf("invalid JWKS file %s: %s", path, err.Error())
+	}
+	return t.AddJWKS(set)
+}
+
+// endregion
+
+// region PEM keys -----------------------------------------------------------------------------------------------------
+
+// RotateSigningKeyPEM adds private key (RSA, ECDSA or Ed25519 in PEM format) identified by kid and makes it the active
+// signing key. Tokens signed by the previous active key are accepted until the grace period ends (zero for ever)
+func (t *TokenUtilsStruct) RotateSigningKeyPEM(kid string, privateKeyPEM []byte, grace time.Duration) error {
+	if len(kid) == 0 {
+		return fmt.Errorf("signing key ID is required")
+	}
+	private, err := parsePrivateKeyPEM(privateKeyPEM)
+	if err != nil {
+		return err
+	}
+	public := private.Public()
+	method, err := signingMethod(public)
+	if err != nil {
+		return err
+	}
+	return t.keys.add(&signingKey{id: kid, method: method, sign: private, verify: public}, true, grace)
+}
+
+// AddVerificationKeyPEM adds public key (RSA, ECDSA or Ed25519 in PEM format) identified by kid, accepted for
+// verification only until expiresAt (zero for ever), kid is required and can't be the kid of a signing key
+func (t *TokenUtilsStruct) AddVerificationKeyPEM(kid string, publicKeyPEM []byte, expiresAt time.Time) error {
+	block, _ := pem.Decode(publicKeyPEM)
+	if block == nil {
+		return fmt.Errorf("invalid PEM public key")
+	}
+
+	var public any
+	var err error
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		public, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			public = cert.PublicKey
+		}
+	default:
+		public, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return err
+	}
+
+	method, err := signingMethod(public)
+	if err != nil {
+		return err
+	}
+	return t.keys.add(&signingKey{id: kid, method: method, verify: public, expiresAt: expiresAt, verifyOnly: true}, false, 0)
+}
+
+// Parse private key in PEM format (PKCS8, PKCS1 or SEC1)
+func parsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid PEM private key")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if signer, ok := key.(crypto.Signer); ok {
+		return signer, nil
+	}
+	return nil, fmt.Errorf("unsupported private key type: %T", key)
+}
+
+// Get the signing method of the public key
+func signingMethod(public any) (jwt.SigningMethod, error) {
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return jwt.SigningMethodES512, nil
+		}
+		return nil, fmt.Errorf("unsupported curve: %s", pub.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("unsupported public key type: %T", public)
+}
+
+// endregion
+
+// region JWK encoding -------------------------------------------------------------------------------------------------
+
+var b64 = base64.RawURLEncoding
+
+// Create JWK from public key (symmetric keys are not supported)
+func newJSONWebKey(kid string, public any) (*JSONWebKey, error) {
+	method, err := signingMethod(public)
+	if err != nil {
+		return nil, err
+	}
+	jwk := &JSONWebKey{Kid: kid, Use: "sig", Alg: method.Alg()}
+
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = b64.EncodeToString(pub.N.Bytes())
+		jwk.E = b64.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = b64.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = b64.EncodeToString(pub)
+	}
+	return jwk, nil
+}
+
+// Get the public key of the JWK
+func (jwk *JSONWebKey) publicKey() (any, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := b64.DecodeString(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64.DecodeString(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", jwk.Crv)
+		}
+		x, err := b64.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64.DecodeString(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if _, err = pub.ECDH(); err != nil {
+			return nil, err
+		}
+		return pub, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve: %s", jwk.Crv)
+		}
+		x, err := b64.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 public key size")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type: %s", jwk.Kty)
+}
+
+// endregion
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// region Signing keys ring --------------------------------------------------------------------------------------------
+
+// signingKey is a JWT signing key identified by the kid header
+type signingKey struct {
+	id         string            // Key ID (the kid header, empty for tokens without kid)
+	method     jwt.SigningMethod // Signing method
+	sign       any               // Key used to sign tokens
+	verify     any               // Key used to verify tokens
+	expiresAt  time.Time         // Verification expiration (zero time for never)
+	verifyOnly bool              // Key accepted for verification only (e.g. key of another issuer or instance)
+}
+
+// keyRing holds the active signing key and the keys accepted for verification
+type keyRing struct {
+	keys   map[string]*signingKey
+	active string
+	mu     sync.RWMutex
+}
+
+// Create key ring with single active key
+func newKeyRing(key *signingKey) *keyRing {
+	return &keyRing{keys: map[string]*signingKey{key.id: key}, active: key.id}
+}
+
+// Replace the signing keys with single active key, verification only keys (e.g. keys of other issuers) are kept
+func (r *keyRing) reset(key *signingKey) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, k := range r.keys {
+		if !k.verifyOnly {
+			delete(r.keys, id)
+		}
+	}
+	r.keys[key.id] = key
+	r.active = key.id
+}
+
+// Add key to the ring, the active key is replaced and kept for verification until the grace period ends
+// Verification only keys must have ID and can't replace the signing keys of this instance
+func (r *keyRing) add(key *signingKey, activate bool, grace time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if key.verifyOnly {
+		if len(key.id) == 0 {
+			return fmt.Errorf("verification key ID is required")
+		}
+		if prev, ok := r.keys[key.id]; ok && !prev.verifyOnly {
+			return fmt.Errorf("verification key ID is used by a signing key: %s", key.id)
+		}
+	}
+
+	if activate {
+		if prev, ok := r.keys[r.active]; ok && prev.id != key.id && grace > 0 {
+			prev.expiresAt = time.Now().Add(grace)
+		}
+		r.active = key.id
+	}
+	r.keys[key.id] = key
+
+	// Remove expired keys
+	for id, k := range r.keys {
+		if id != r.active && !k.expiresAt.IsZero() && time.Now().After(k.expiresAt) {
+			delete(r.keys, id)
+		}
+	}
+	return nil
+}
+
+// Remove key from the ring (the active key can't be removed)
+func (r *keyRing) remove(kid string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if kid == r.active {
+		return fmt.Errorf("active signing key can't be removed: %s", kid)
+	}
+	delete(r.keys, kid)
+	return nil
+}
+
+// Get the active signing key
+func (r *keyRing) signingKey() (*signingKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if key, ok := r.keys[r.active]; ok && key.sign != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("no active signing key")
+}
+
+// Get the verification key of the token by its kid header and signing method
+func (r *keyRing) verificationKey(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	r.mu.RLock()
+	key, ok := r.keys[kid]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %s", kid)
+	}
+	if !key.expiresAt.IsZero() && time.Now().After(key.expiresAt) {
+		return nil, fmt.Errorf("signing key expired: %s", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %s", token.Method.Alg())
+	}
+	return key.verify, nil
+}
+
+// List the keys of the ring (sorted by ID), expired keys are excluded
+func (r *keyRing) list() []*signingKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	result := make([]*signingKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		if key.expiresAt.IsZero() || time.Now().Before(key.expiresAt) {
+			result = append(result, key)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].id < result[j].id })
+	return result
+}
+
+// endregion
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// region Revocation store ---------------------------------------------------------------------------------------------
+
+// IRevocationStore keeps the IDs (jti claim) of revoked tokens until they expire
+// Implement this interface to share the revocation list between server instances (e.g. Redis)
+type IRevocationStore interface {
+	Revoke(tokenId string, expiresAt time.Time) error                     // Revoke token ID until its expiration (zero time for never)
+	RevokeIfNotRevoked(tokenId string, expiresAt time.Time) (bool, error) // Atomically revoke token ID, false if it was already revoked
+	IsRevoked(tokenId string) (bool, error)                               // Check if the token ID is revoked
+}
+
+// MemoryRevocationStore is an in-memory revocation list (per process)
+type MemoryRevocationStore struct {
+	revoked   map[string]time.Time
+	lastSweep time.Time
+	mu        sync.RWMutex
+}
+
+// NewMemoryRevocationStore creates in-memory revocation list
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{revoked: make(map[string]time.Time), lastSweep: time.Now()}
+}
+
+// Revoke token ID until its expiration (zero time for never)
+func (m *MemoryRevocationStore) Revoke(tokenId string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep()
+	m.revoked[tokenId] = expiresAt
+	return nil
+}
+
+// RevokeIfNotRevoked revokes token ID until its expiration, returns false if the token ID was already revoked
+func (m *MemoryRevocationStore) RevokeIfNotRevoked(tokenId string, expiresAt time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep()
+	if m.isRevoked(tokenId) {
+		return false, nil
+	}
+	m.revoked[tokenId] = expiresAt
+	return true, nil
+}
+
+// IsRevoked checks if the token ID is revoked
+func (m *MemoryRevocationStore) IsRevoked(tokenId string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.isRevoked(tokenId), nil
+}
+
+// Check if the token ID is revoked (must be called under lock)
+func (m *MemoryRevocationStore) isRevoked(tokenId string) bool {
+	exp, ok := m.revoked[tokenId]
+	return ok && (exp.IsZero() || time.Now().Before(exp))
+}
+
+// Remove expired entries once a minute, the tokens are rejected anyway (must be called under lock)
+func (m *MemoryRevocationStore) sweep() {
+	now := time.Now()
+	if now.Sub(m.lastSweep) > time.Minute {
+		for id, exp := range m.revoked {
+			if !exp.IsZero() && now.After(exp) {
+				delete(m.revoked, id)
+			}
+		}
+		m.lastSweep = now
+	}
+}
+
+// endregion
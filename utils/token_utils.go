@@ -4,64 +4,312 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"slices"
+	"strings"
 	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 
 	. "github.com/go-yaaf/yaaf-common-net/model"
 )
 
-var tokenSecret = []byte{0x47, 0x30, 0x30, 0x78, 0x77, 0x30, 0x72, 0x6b, 0x4c, 0x69, 0x67, 0x68, 0x74, 0x63, 0x4c, 0x40, 0x75, 0x64, 0x53, 0x33, 0x43, 0x52, 0x33, 0x54, 0x4b, 0x33, 0x59, 0x74, 0x30, 0x4b, 0x65, 0x4f}
-var signingKy = []byte{0x40, 0x79, 0x61, 0x46, 0x6c, 0x69, 0x67, 0x68, 0x74, 0x73, 0x40, 0x75, 0x64, 0x53, 0x33, 0x43, 0x52, 0x33, 0x54, 0x40, 0x50, 0x69, 0x4b, 0x33, 0x59, 0x74, 0x30, 0x4b, 0x65, 0x4f, 0x33, 0x32}
-
-//var tokenSecret = []byte{}
-//var signingKy = []byte{}
+// Default secrets (used when no secrets are provided)
+var defaultTokenSecret = []byte{0x47, 0x30, 0x30, 0x78, 0x77, 0x30, 0x72, 0x6b, 0x4c, 0x69, 0x67, 0x68, 0x74, 0x63, 0x4c, 0x40, 0x75, 0x64, 0x53, 0x33, 0x43, 0x52, 0x33, 0x54, 0x4b, 0x33, 0x59, 0x74, 0x30, 0x4b, 0x65, 0x4f}
+var defaultSigningKey = []byte{0x40, 0x79, 0x61, 0x46, 0x6c, 0x69, 0x67, 0x68, 0x74, 0x73, 0x40, 0x75, 0x64, 0x53, 0x33, 0x43, 0x52, 0x33, 0x54, 0x40, 0x50, 0x69, 0x4b, 0x33, 0x59, 0x74, 0x30, 0x4b, 0x65, 0x4f, 0x33, 0x32}
 
 // TokenUtilsStruct is a structure for token utilities
 type TokenUtilsStruct struct {
+	apiSecrets    map[string][]byte // API key encryption secrets by version (empty version for legacy keys)
+	apiVersion    string            // Active API key encryption secret version
+	apiMu         sync.RWMutex      // API key encryption secrets lock
+	keys          *keyRing          // JWT signing keys
+	accessTTL     time.Duration     // Access token time to live
+	refreshTTL    time.Duration     // Refresh token time to live
+	revoked       IRevocationStore  // Revoked tokens list
+	issuer        string            // Issuer of the created tokens (iss claim)
+	issuers       []string          // Accepted issuers (empty for any issuer)
+	audience      []string          // Audience of the created tokens and the accepted audience (aud claim)
+	clockSkew     time.Duration     // Allowed clock skew when validating exp, nbf and iat claims
+	legacyApiKeys bool              // Accept legacy (not authenticated) API keys (default: disabled)
 }
 
 var doOnceForTokenUtils sync.Once
 
 var tokenUtilsSingleton *TokenUtilsStruct = nil
 
-// TokenUtils is a factory method that acts as a static member
+// TokenUtils is a factory method that acts as a static member (process-wide instance)
 func TokenUtils() *TokenUtilsStruct {
 	doOnceForTokenUtils.Do(func() {
-		tokenUtilsSingleton = &TokenUtilsStruct{}
+		tokenUtilsSingleton = NewTokenUtils()
 	})
 	return tokenUtilsSingleton
 }
 
-// WithSecrets sets the API secret and signing key
+// NewTokenUtils creates an independent token utilities instance with its own secrets (use WithSecrets to set them)
+func NewTokenUtils() *TokenUtilsStruct {
+	return &TokenUtilsStruct{
+		apiSecrets: map[string][]byte{"": defaultTokenSecret},
+		keys:       newKeyRing(hmacKey("", defaultSigningKey)),
+		accessTTL:  30 * time.Minute,
+		refreshTTL: 7 * 24 * time.Hour,
+		revoked:    NewMemoryRevocationStore(),
+		clockSkew:  30 * time.Second,
+	}
+}
+
+// WithSecrets sets the API secret and signing key (replaces the API secrets and the signing keys)
 func (t *TokenUtilsStruct) WithSecrets(apiSecret, signingKey string) *TokenUtilsStruct {
 	if len(apiSecret) < 32 {
 		panic(errors.New("api secret too short"))
 	}
-	tokenSecret = []byte(apiSecret[:32])
+	t.apiMu.Lock()
+	t.apiSecrets = map[string][]byte{"": []byte(apiSecret[:32])}
+	t.apiVersion = ""
+	t.apiMu.Unlock()
 
 	if len(signingKey) < 32 {
 		panic(errors.New("signing key too short"))
 	}
-	signingKy = []byte(signingKey[:32])
+	t.keys.reset(hmacKey("", []byte(signingKey[:32])))
+	return t
+}
+
+// WithTokenTTL sets the time to live of access tokens (default: 30 minutes) and refresh tokens (default: 7 days)
+func (t *TokenUtilsStruct) WithTokenTTL(access, refresh time.Duration) *TokenUtilsStruct {
+	t.accessTTL = access
+	t.refreshTTL = refresh
+	return t
+}
+
+// WithRevocationStore replaces the in-memory revoked tokens list (e.g. to share it between server instances)
+func (t *TokenUtilsStruct) WithRevocationStore(store IRevocationStore) *TokenUtilsStruct {
+	t.revoked = store
+	return t
+}
+
+// WithIssuer sets the issuer of the created tokens (iss claim), tokens of other issuers are rejected
+// unless listed in the trusted issuers (e.g. tokens of the identity service)
+func (t *TokenUtilsStruct) WithIssuer(issuer string, trusted ...string) *TokenUtilsStruct {
+	t.issuer = issuer
+	t.issuers = append([]string{issuer}, trusted...)
+	return t
+}
+
+// WithAudience sets the audience of the created tokens (aud claim), tokens not intended for any of the audience
+// are rejected
+func (t *TokenUtilsStruct) WithAudience(audience ...string) *TokenUtilsStruct {
+	t.audience = audience
 	return t
 }
 
+// WithClockSkew sets the allowed clock skew between servers when validating the token times (default: 30 seconds)
+func (t *TokenUtilsStruct) WithClockSkew(skew time.Duration) *TokenUtilsStruct {
+	t.clockSkew = skew
+	return t
+}
+
+// AccessTokenTTL returns the time to live of access tokens
+func (t *TokenUtilsStruct) AccessTokenTTL() time.Duration {
+	return t.accessTTL
+}
+
+// region Keys rotation ------------------------------------------------------------------------------------------------
+
+// RotateSigningKey adds signing key (at least 32 characters) identified by kid and makes it the active signing key
+// Tokens signed by the previous active key are accepted until the grace period ends (zero grace period for ever)
+func (t *TokenUtilsStruct) RotateSigningKey(kid, key string, grace time.Duration) error {
+	if len(kid) == 0 {
+		return fmt.Errorf("signing key ID is required")
+	}
+	if len(key) < 32 {
+		return fmt.Errorf("signing key too short")
+	}
+	return t.keys.add(hmacKey(kid, []byte(key)), true, grace)
+}
+
+// AddVerificationKey adds signing key identified by kid, accepted for verification only until expiresAt (zero for ever)
+// e.g. keys of other server instances that were not rotated yet (kid is required and can't be the kid of a signing key)
+func (t *TokenUtilsStruct) AddVerificationKey(kid, key string, expiresAt time.Time) error {
+	if len(key) < 32 {
+		return fmt.Errorf("signing key too short")
+	}
+	k := hmacKey(kid, []byte(key))
+	k.expiresAt = expiresAt
+	k.verifyOnly = true
+	return t.keys.add(k, false, 0)
+}
+
+// RemoveSigningKey removes the signing key, tokens signed by this key are rejected
+func (t *TokenUtilsStruct) RemoveSigningKey(kid string) error {
+	return t.keys.remove(kid)
+}
+
+// RotateApiSecret adds API key encryption secret (at least 32 characters) and makes it the active secret
+// New API keys are prefixed by the version (version.key), existing API keys are parsed by the secret of their version
+func (t *TokenUtilsStruct) RotateApiSecret(version, secret string) error {
+	if len(version) == 0 || strings.Contains(version, ".") || version+"." == apiKeyPrefix {
+		return fmt.Errorf("invalid api secret version: %s", version)
+	}
+	if len(secret) < 32 {
+		return fmt.Errorf("api secret too short")
+	}
+	t.apiMu.Lock()
+	defer t.apiMu.Unlock()
+	t.apiSecrets[version] = []byte(secret[:32])
+	t.apiVersion = version
+	return nil
+}
+
+// RemoveApiSecret removes API key encryption secret, API keys of this version are rejected
+func (t *TokenUtilsStruct) RemoveApiSecret(version string) error {
+	t.apiMu.Lock()
+	defer t.apiMu.Unlock()
+	if version == t.apiVersion {
+		return fmt.Errorf("active api secret can't be removed: %s", version)
+	}
+	delete(t.apiSecrets, version)
+	return nil
+}
+
+// Create HMAC signing key
+func hmacKey(kid string, key []byte) *signingKey {
+	return &signingKey{id: kid, method: jwt.SigningMethodHS256, sign: key, verify: key}
+}
+
+// endregion
+
 // region Access Token parsing helpers ---------------------------------------------------------------------------------
 
+// Token types (the tokenType claim)
+const (
+	accessTokenType  = ""
+	refreshTokenType = "refresh"
+)
+
 // TokenClaims represents the claims in a JWT token
 type TokenClaims struct {
 	jwt.RegisteredClaims
 	TokenData
+	TokenType string `json:"tokenType,omitempty"` // Token type (empty for access token)
 }
 
 // CreateToken build JWT token from Token Data structure
+// The token expires at TokenData.ExpiresIn (exp claim), token without ExpiresIn does not expire
+// The token ID (jti claim) is generated unless provided (e.g. renewed token keeps the ID of the original token)
 func (t *TokenUtilsStruct) CreateToken(td *TokenData) (string, error) {
-	claims := TokenClaims{}
+	claims := t.newTokenClaims(td)
+	if len(claims.ID) == 0 {
+		claims.ID = uuid.New().String()
+	}
+	return t.sign(claims)
+}
+
+// ParseToken rebuild Token Data structure from JWT token
+// Expired tokens, tokens of other issuers or audience, refresh tokens and revoked tokens are rejected
+func (t *TokenUtilsStruct) ParseToken(tokenString string) (*TokenData, error) {
+	claims, err := t.parseClaims(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != accessTokenType {
+		return nil, fmt.Errorf("invalid token type: %s", claims.TokenType)
+	}
+	return claims.tokenData(), nil
+}
+
+// endregion
+
+// region Refresh Token helpers ----------------------------------------------------------------------------------------
+
+// CreateTokenPair issues access token and refresh token for the Token Data structure
+func (t *TokenUtilsStruct) CreateTokenPair(td *TokenData) (*TokenPair, error) {
+	now := time.Now()
+
+	access := *td
+	access.TokenId = uuid.New().String()
+	access.ExpiresIn = now.Add(t.accessTTL).UnixMilli()
+	accessToken, err := t.CreateToken(&access)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh := *td
+	refresh.TokenId = uuid.New().String()
+	refresh.ExpiresIn = now.Add(t.refreshTTL).UnixMilli()
+	claims := t.newTokenClaims(&refresh)
+	claims.TokenType = refreshTokenType
+	refreshToken, err := t.sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{AccessToken: accessToken, RefreshToken: refreshToken, ExpiresIn: access.ExpiresIn}, nil
+}
+
+// RefreshToken exchanges refresh token for a new token pair, the refresh token is revoked (single use)
+func (t *TokenUtilsStruct) RefreshToken(refreshToken string) (*TokenPair, error) {
+	claims, err := t.parseClaims(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != refreshTokenType {
+		return nil, fmt.Errorf("not a refresh token")
+	}
+	if len(claims.ID) == 0 {
+		return nil, fmt.Errorf("refresh token without ID")
+	}
+
+	// Revoke atomically, so concurrent requests can't exchange the same refresh token
+	if revoked, er := t.revoked.RevokeIfNotRevoked(claims.ID, t.revocationExpiry(claims)); er != nil {
+		return nil, er
+	} else if !revoked {
+		return nil, fmt.Errorf("token revoked")
+	}
+
+	td := claims.tokenData()
+	td.TokenId = ""
+	return t.CreateTokenPair(td)
+}
+
+// RevokeToken revokes access token or refresh token (e.g. on logout)
+func (t *TokenUtilsStruct) RevokeToken(tokenString string) error {
+	claims, err := t.parseClaims(tokenString)
+	if err != nil {
+		return err
+	}
+	return t.revoked.Revoke(claims.ID, t.revocationExpiry(claims))
+}
+
+// RevokeTokenId revokes the token ID (TokenData.TokenId) until the provided time (zero time for never)
+func (t *TokenUtilsStruct) RevokeTokenId(tokenId string, until time.Time) error {
+	return t.revoked.Revoke(tokenId, until)
+}
+
+// endregion
+
+// region Token helpers ------------------------------------------------------------------------------------------------
+
+// Build token claims from Token Data structure (the exp claim is synced with ExpiresIn)
+func (t *TokenUtilsStruct) newTokenClaims(td *TokenData) *TokenClaims {
+	now := time.Now()
+	claims := &TokenClaims{}
+	claims.Issuer = t.issuer
+	claims.Audience = t.audience
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
+	if td.ExpiresIn > 0 {
+		claims.ExpiresAt = jwt.NewNumericDate(time.UnixMilli(td.ExpiresIn))
+	}
 	claims.AccountId = td.AccountId
 	claims.SubjectId = td.SubjectId
 	claims.SubjectType = td.SubjectType
@@ -69,60 +317,216 @@ func (t *TokenUtilsStruct) CreateToken(td *TokenData) (string, error) {
 	claims.Status = td.Status
 	claims.ExpiresIn = td.ExpiresIn
 	claims.Subject = td.SubjectId
+	claims.ID = td.TokenId
+	claims.Claims = td.Claims
+	return claims
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(signingKy)
+// Build Token Data structure from token claims
+func (claims *TokenClaims) tokenData() *TokenData {
+	return &TokenData{
+		AccountId:   claims.AccountId,
+		SubjectId:   claims.SubjectId,
+		SubjectType: claims.SubjectType,
+		SubjectRole: claims.SubjectRole,
+		Status:      claims.Status,
+		ExpiresIn:   claims.ExpiresIn,
+		TokenId:     claims.ID,
+		Claims:      claims.Claims,
+	}
 }
 
-// ParseToken rebuild Token Data structure from JWT token
-func (t *TokenUtilsStruct) ParseToken(tokenString string) (*TokenData, error) {
+// Sign the token claims by the active signing key
+func (t *TokenUtilsStruct) sign(claims *TokenClaims) (string, error) {
+	key, err := t.keys.signingKey()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.method, claims)
+	if len(key.id) > 0 {
+		token.Header["kid"] = key.id
+	}
+	return token.SignedString(key.sign)
+}
 
-	// Parse the token
-	token, err := jwt.ParseWithClaims(tokenString, &TokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return signingKy, nil
-	})
+// Parse and validate the token, revoked tokens are rejected
+func (t *TokenUtilsStruct) parseClaims(tokenString string) (*TokenClaims, error) {
 
+	// Parse the token (the exp, nbf and iat claims are validated by the parser)
+	parser := jwt.NewParser(jwt.WithLeeway(t.clockSkew), jwt.WithIssuedAt())
+	token, err := parser.ParseWithClaims(tokenString, &TokenClaims{}, t.keys.verificationKey)
 	if err != nil {
 		return nil, err
 	}
 
 	// Validate the token and extract the claims
-	if claims, ok := token.Claims.(*TokenClaims); ok && token.Valid {
-		return &TokenData{
-			AccountId:   claims.AccountId,
-			SubjectId:   claims.SubjectId,
-			SubjectType: claims.SubjectType,
-			SubjectRole: claims.SubjectRole,
-			Status:      claims.Status,
-			ExpiresIn:   claims.ExpiresIn,
-		}, nil
-	} else {
+	claims, ok := token.Claims.(*TokenClaims)
+	if !ok || !token.Valid {
 		return nil, fmt.Errorf("invalid token")
 	}
+
+	// Tokens created before the exp claim was set expire by ExpiresIn
+	if claims.ExpiresAt == nil && claims.ExpiresIn > 0 && time.Now().Add(-t.clockSkew).UnixMilli() > claims.ExpiresIn {
+		return nil, jwt.ErrTokenExpired
+	}
+	if len(t.issuers) > 0 && !slices.Contains(t.issuers, claims.Issuer) {
+		return nil, fmt.Errorf("%w: %s", jwt.ErrTokenInvalidIssuer, claims.Issuer)
+	}
+	if len(t.audience) > 0 && !slices.ContainsFunc(claims.Audience, func(aud string) bool { return slices.Contains(t.audience, aud) }) {
+		return nil, jwt.ErrTokenInvalidAudience
+	}
+
+	if len(claims.ID) > 0 {
+		if revoked, er := t.revoked.IsRevoked(claims.ID); er != nil {
+			return nil, er
+		} else if revoked {
+			return nil, fmt.Errorf("token revoked")
+		}
+	}
+	return claims, nil
+}
+
+// Get the time until the token ID should be kept in the revoked list (zero time for tokens without expiration)
+// Access tokens may be renewed with the same ID, so the revocation covers at least the access token time to live
+func (t *TokenUtilsStruct) revocationExpiry(claims *TokenClaims) time.Time {
+	var until time.Time
+	if claims.ExpiresAt != nil {
+		until = claims.ExpiresAt.Add(t.clockSkew)
+	} else if claims.ExpiresIn > 0 {
+		until = time.UnixMilli(claims.ExpiresIn).Add(t.clockSkew)
+	} else {
+		return time.Time{}
+	}
+	if claims.TokenType == accessTokenType {
+		if renewed := time.Now().Add(t.accessTTL + t.clockSkew); renewed.After(until) {
+			until = renewed
+		}
+	}
+	return until
 }
 
 // endregion
 
 // region API Key parsing helpers --------------------------------------------------------------------------------------
 
-// CreateApiKey generate API Key from application name
+// API key format prefix (AES-GCM sealed ApiKeyInfo): ak1.[secret version.]payload
+const apiKeyPrefix = "ak1."
+
+// CreateApiKey generate API Key from application name, without scopes and expiration
 func (t *TokenUtilsStruct) CreateApiKey(appName string) (string, error) {
-	t.ensureKeys()
-	return encrypt(appName)
+	return t.CreateScopedApiKey(appName, 0)
 }
 
-// ParseApiKey extract application name from API key
-func (t *TokenUtilsStruct) ParseApiKey(apiKey string) (string, error) {
-	t.ensureKeys()
-	return decrypt(apiKey)
+// CreateScopedApiKey generate API Key from application name with the granted scopes and time to live (0 for never)
+// The key is encrypted and authenticated (AES-GCM) by the active secret (see RotateApiSecret)
+func (t *TokenUtilsStruct) CreateScopedApiKey(appName string, ttl time.Duration, scopes ...string) (string, error) {
+	now := time.Now()
+	info := &ApiKeyInfo{KeyId: uuid.New().String(), AppName: appName, Scopes: scopes, IssuedAt: now.UnixMilli()}
+	if ttl > 0 {
+		info.ExpiresAt = now.Add(ttl).UnixMilli()
+	}
+
+	t.apiMu.RLock()
+	version, secret := t.apiVersion, t.apiSecrets[t.apiVersion]
+	t.apiMu.RUnlock()
+	ensureSecret(secret)
+
+	prefix := apiKeyPrefix
+	if len(version) > 0 {
+		prefix += version + "."
+	}
+	payload, err := json.Marshal(info)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(secret, payload, []byte(prefix))
+	if err != nil {
+		return "", err
+	}
+	return prefix + sealed, nil
 }
 
-func (t *TokenUtilsStruct) ensureKeys() {
-	if len(tokenSecret) < 32 {
-		panic(errors.New("encryption secret is not set, please use WithSecrets to set it"))
+// ParseApiKey extract application info from API key, tampered and expired API keys are rejected
+// Legacy API keys (application name only) are rejected unless enabled (see WithLegacyApiKeys)
+func (t *TokenUtilsStruct) ParseApiKey(apiKey string) (*ApiKeyInfo, error) {
+	if !strings.HasPrefix(apiKey, apiKeyPrefix) {
+		return t.parseLegacyApiKey(apiKey)
+	}
+
+	version, payload := "", apiKey[len(apiKeyPrefix):]
+	if idx := strings.IndexByte(payload, '.'); idx >= 0 {
+		version, payload = payload[:idx], payload[idx+1:]
 	}
-	if len(signingKy) < 32 {
-		panic(errors.New("encryption signing key is not set, please use WithSecrets to set it"))
+	secret, err := t.apiSecret(version)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := unseal(secret, payload, []byte(apiKey[:len(apiKey)-len(payload)]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid api key")
+	}
+	info := &ApiKeyInfo{}
+	if err = json.Unmarshal(data, info); err != nil {
+		return nil, fmt.Errorf("invalid api key")
+	}
+	if info.ExpiresAt > 0 && time.Now().Add(-t.clockSkew).UnixMilli() > info.ExpiresAt {
+		return nil, fmt.Errorf("api key expired")
+	}
+	return info, nil
+}
+
+// WithLegacyApiKeys enables or disables the legacy API keys format (default: disabled)
+// Legacy keys are not authenticated (AES-CTR), a legacy key can be tampered to another application name, so enable
+// them only for migration of existing clients. Breaking change: earlier versions accepted them by default, services
+// upgrading with deployed legacy keys must enable them until the clients use new keys (see the README Upgrading section)
+func (t *TokenUtilsStruct) WithLegacyApiKeys(enabled bool) *TokenUtilsStruct {
+	t.legacyApiKeys = enabled
+	return t
+}
+
+// Parse legacy API key format: [secret version.]hex(AES-CTR(application name))
+func (t *TokenUtilsStruct) parseLegacyApiKey(apiKey string) (*ApiKeyInfo, error) {
+	if !t.legacyApiKeys {
+		return nil, fmt.Errorf("legacy api keys are disabled (see WithLegacyApiKeys)")
+	}
+
+	version := ""
+	if idx := strings.IndexByte(apiKey, '.'); idx >= 0 {
+		version, apiKey = apiKey[:idx], apiKey[idx+1:]
+	}
+	secret, err := t.apiSecret(version)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := decrypt(secret, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// The legacy format has no integrity check, reject keys decrypted to non printable names
+	if len(name) == 0 || !utf8.ValidString(name) || strings.IndexFunc(name, func(r rune) bool { return !unicode.IsPrint(r) }) >= 0 {
+		return nil, fmt.Errorf("invalid api key")
+	}
+	return &ApiKeyInfo{AppName: name}, nil
+}
+
+// Get the API key encryption secret of the version
+func (t *TokenUtilsStruct) apiSecret(version string) ([]byte, error) {
+	t.apiMu.RLock()
+	secret, ok := t.apiSecrets[version]
+	t.apiMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown api key version: %s", version)
+	}
+	ensureSecret(secret)
+	return secret, nil
+}
+
+func ensureSecret(secret []byte) {
+	if len(secret) < 32 {
+		panic(errors.New("encryption secret is not set, please use WithSecrets to set it"))
 	}
 }
 
@@ -130,35 +534,52 @@ func (t *TokenUtilsStruct) ensureKeys() {
 
 // region PRIVATE SECTION ----------------------------------------------------------------------------------------------
 
-// encrypt string using AES and return base64
-func encrypt(value string) (string, error) {
-
-	block, err := aes.NewCipher(tokenSecret)
+// seal value using AES-GCM (authenticated encryption) and return base64 URL encoded nonce and cipher text
+func seal(secret, value, additionalData []byte) (string, error) {
+	gcm, err := newGCM(secret)
 	if err != nil {
 		return "", err
 	}
-
-	// Generate a new random IV
-	cipherText := make([]byte, aes.BlockSize+len(value))
-	iv := cipherText[:aes.BlockSize]
-	if _, er := io.ReadFull(rand.Reader, iv); er != nil {
+	nonce := make([]byte, gcm.NonceSize())
+	if _, er := io.ReadFull(rand.Reader, nonce); er != nil {
 		return "", er
 	}
+	return base64.RawURLEncoding.EncodeToString(gcm.Seal(nonce, nonce, value, additionalData)), nil
+}
 
-	stream := cipher.NewCTR(block, iv)
-	stream.XORKeyStream(cipherText[aes.BlockSize:], []byte(value))
+// unseal base64 URL encoded value sealed by AES-GCM, tampered values are rejected
+func unseal(secret []byte, value string, additionalData []byte) ([]byte, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(secret)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("cipher text too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], additionalData)
+}
 
-	return hex.EncodeToString(cipherText), nil
+// Create AES-GCM cipher
+func newGCM(secret []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
 }
 
 // decrypt base64 string using AES
-func decrypt(value string) (string, error) {
+func decrypt(secret []byte, value string) (string, error) {
 	cipherTextBytes, err := hex.DecodeString(value)
 	if err != nil {
 		return "", err
 	}
 
-	block, err := aes.NewCipher(tokenSecret)
+	block, err := aes.NewCipher(secret)
 	if err != nil {
 		return "", err
 	}
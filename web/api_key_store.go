@@ -0,0 +1,281 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-yaaf/yaaf-common/logger"
+
+	. "github.com/go-yaaf/yaaf-common-net/model"
+)
+
+// region API key store ------------------------------------------------------------------------------------------------
+
+// IApiKeyStore is the registry of the issued API keys (by key ID)
+// Implement this interface to share the registry between server instances (e.g. database)
+type IApiKeyStore interface {
+	Get(keyId string) (*ApiKeyRecord, error)        // Get the key record (nil if not registered)
+	List(appName string) ([]*ApiKeyRecord, error)   // List the key records of the application (empty for all)
+	Save(record *ApiKeyRecord) error                // Add or replace the key record
+	Revoke(keyId string, revokedAt time.Time) error // Mark the key as revoked
+}
+
+// MemoryApiKeyStore is an in-memory API key registry (per process)
+type MemoryApiKeyStore struct {
+	records map[string]ApiKeyRecord
+	mu      sync.RWMutex
+}
+
+// NewMemoryApiKeyStore creates in-memory API key registry
+func NewMemoryApiKeyStore() *MemoryApiKeyStore {
+	return &MemoryApiKeyStore{records: make(map[string]ApiKeyRecord)}
+}
+
+// Get the key record (nil if not registered)
+func (m *MemoryApiKeyStore) Get(keyId string) (*ApiKeyRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if record, ok := m.records[keyId]; ok {
+		return &record, nil
+	}
+	return nil, nil
+}
+
+// List the key records of the application (empty for all), sorted by creation time
+func (m *MemoryApiKeyStore) List(appName string) ([]*ApiKeyRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make([]*ApiKeyRecord, 0, len(m.records))
+	for _, record := range m.records {
+		if len(appName) == 0 || record.AppName == appName {
+			r := record
+			result = append(result, &r)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].CreatedAt != result[j].CreatedAt {
+			return result[i].CreatedAt < result[j].CreatedAt
+		}
+		return result[i].KeyId < result[j].KeyId
+	})
+	return result, nil
+}
+
+// Save adds or replaces the key record
+func (m *MemoryApiKeyStore) Save(record *ApiKeyRecord) error {
+	if len(record.KeyId) == 0 {
+		return fmt.Errorf("API key ID is required")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[record.KeyId] = *record
+	return nil
+}
+
+// Revoke marks the key as revoked (the first revocation time is kept)
+func (m *MemoryApiKeyStore) Revoke(keyId string, revokedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, ok := m.records[keyId]
+	if !ok {
+		return fmt.Errorf("API key not registered: %s", keyId)
+	}
+	if !record.IsRevoked() {
+		record.RevokedAt = revokedAt.UnixMilli()
+		m.records[keyId] = record
+	}
+	return nil
+}
+
+// FileApiKeyStore is an API key registry persisted to JSON file (rewritten on every change)
+type FileApiKeyStore struct {
+	MemoryApiKeyStore
+	path    string
+	writeMu sync.Mutex // Serializes the changes, so the file is rewritten in the order of the changes
+}
+
+// NewFileApiKeyStore creates API key registry persisted to the JSON file (created on the first change if not exists)
+func NewFileApiKeyStore(path string) (*FileApiKeyStore, error) {
+	store := &FileApiKeyStore{MemoryApiKeyStore: *NewMemoryApiKeyStore(), path: path}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var list []ApiKeyRecord
+	if err = json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid API key store file %s: %s", path, err.Error())
+	}
+	for _, record := range list {
+		store.records[record.KeyId] = record
+	}
+	return store, nil
+}
+
+// Save adds or replaces the key record and rewrites the file
+func (f *FileApiKeyStore) Save(record *ApiKeyRecord) error {
+	f.writeMu.Lock()
+	defer f.writeMu.Unlock()
+	if err := f.MemoryApiKeyStore.Save(record); err != nil {
+		return err
+	}
+	return f.persist()
+}
+
+// Revoke marks the key as revoked and rewrites the file
+func (f *FileApiKeyStore) Revoke(keyId string, revokedAt time.Time) error {
+	f.writeMu.Lock()
+	defer f.writeMu.Unlock()
+	if err := f.MemoryApiKeyStore.Revoke(keyId, revokedAt); err != nil {
+		return err
+	}
+	return f.persist()
+}
+
+// Write the records to temporary file and replace the store file (must be called under the write lock)
+func (f *FileApiKeyStore) persist() error {
+	list, _ := f.List("")
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// WithApiKeyStore sets the API key registry, only registered and not revoked keys are accepted by ApiKeyAuthenticator
+// (legacy keys without key ID are rejected) and the per-key allowed origins, IP ranges and rate limit tier are applied
+func (s *Server) WithApiKeyStore(store IApiKeyStore) *Server {
+	s.apiKeys = store
+	return s
+}
+
+// endregion
+
+// region API key record checks ----------------------------------------------------------------------------------------
+
+// Check the registered record of the API key and store it in the request context
+func (s *Server) checkApiKeyRecord(c *gin.Context, info *ApiKeyInfo) error {
+
+	restPath := strings.ToLower(c.Request.URL.Path)
+	if len(info.KeyId) == 0 {
+		return NewForbiddenError("API key is not registered for path: %s", restPath)
+	}
+
+	record, err := s.apiKeys.Get(info.KeyId)
+	if err != nil {
+		return apiKeyStoreError(c, err)
+	}
+	if record == nil || record.AppName != info.AppName {
+		return NewForbiddenError("API key is not registered for path: %s", restPath)
+	}
+	if record.IsRevoked() {
+		return NewForbiddenError("API key is revoked for path: %s", restPath)
+	}
+
+	// Requests without Origin header are not sent by browsers and are not checked
+	if origin := c.GetHeader("Origin"); len(origin) > 0 && len(record.AllowedOrigins) > 0 {
+		if !originAllowed(record.AllowedOrigins, origin) {
+			return NewForbiddenError("API key is not allowed from origin: %s", origin)
+		}
+	}
+	if len(record.AllowedIPs) > 0 && !ipAllowed(record.AllowedIPs, c.ClientIP()) {
+		return NewForbiddenError("API key is not allowed from IP: %s", c.ClientIP())
+	}
+
+	c.Set(apiKeyRecordContextKey, record)
+	return nil
+}
+
+// Get the registered API key record from the request context
+func contextApiKeyRecord(c *gin.Context) *ApiKeyRecord {
+	if v, ok := c.Get(apiKeyRecordContextKey); ok {
+		if record, ok := v.(*ApiKeyRecord); ok {
+			return record
+		}
+	}
+	return nil
+}
+
+// Check if the origin matches any of the patterns (invalid patterns never match)
+func originAllowed(patterns []string, origin string) bool {
+	for _, pattern := range patterns {
+		anyOrigin, matchers, err := compileOrigins([]string{pattern})
+		if err != nil {
+			continue
+		}
+		if anyOrigin || (len(matchers) > 0 && matchers[0](origin)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check if the IP address matches any of the addresses or CIDR ranges (invalid ranges never match)
+func ipAllowed(ranges []string, address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, r := range ranges {
+		if strings.Contains(r, "/") {
+			if _, network, err := net.ParseCIDR(r); err == nil && network.Contains(ip) {
+				return true
+			}
+		} else if allowed := net.ParseIP(r); allowed != nil && allowed.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate the allowed origins and IP ranges of the key record
+func validateApiKeyRecord(record *ApiKeyRecord) error {
+	verr := &ValidationError{}
+	for _, origin := range record.AllowedOrigins {
+		if _, _, err := compileOrigins([]string{origin}); err != nil {
+			verr.Add("allowedOrigins", origin, "invalid origin pattern")
+		}
+	}
+	for _, r := range record.AllowedIPs {
+		if _, _, err := net.ParseCIDR(r); err != nil && net.ParseIP(r) == nil {
+			verr.Add("allowedIps", r, "invalid IP address or CIDR range")
+		}
+	}
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// Log the API key store failure and return an internal error without the store details (e.g. DSN, host or user)
+func apiKeyStoreError(c *gin.Context, err error) error {
+	logger.Error("API key store error on path: %s: %v", c.Request.URL.Path, err)
+	return NewInternalError("API key store error")
+}
+
+// endregion
@@ -0,0 +1,193 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-yaaf/yaaf-common/logger"
+
+	. "github.com/go-yaaf/yaaf-common-net/model"
+)
+
+// region Endpoint structure and factory method ------------------------------------------------------------------------
+
+// CreateApiKeyRequest is the request to issue and register a new API key
+// @Data
+type CreateApiKeyRequest struct {
+	AppName        string   `json:"app" binding:"required"`    // Application name
+	Description    string   `json:"description"`               // Key description (e.g. the key owner)
+	Scopes         []string `json:"scopes"`                    // Granted scopes, e.g. heroes:read
+	ExpiresIn      int64    `json:"expiresIn" binding:"gte=0"` // Time to live in seconds (0 for never)
+	AllowedOrigins []string `json:"allowedOrigins"`            // Allowed Origin header patterns (empty for any)
+	AllowedIPs     []string `json:"allowedIps"`                // Allowed client IP addresses or CIDR ranges (empty for any)
+	RateLimitTier  string   `json:"tier"`                      // Rate limit tier name (see WithRateLimitTiers)
+}
+
+// ApiKeyIdRequest is the request addressing a registered API key
+// @Data
+type ApiKeyIdRequest struct {
+	KeyId string `uri:"kid" binding:"required"` // The API key ID
+}
+
+// ListApiKeysRequest is the request to list the registered API keys
+// @Data
+type ListApiKeysRequest struct {
+	AppName string `form:"app"` // Filter by application name (empty for all)
+}
+
+// ApiKeyResponse message is returned for the registered API key (the key itself is returned on creation only)
+// @Data
+type ApiKeyResponse struct {
+	BaseRestResponse
+	ApiKey string       `json:"apiKey,omitempty"` // The issued API key (returned once, on creation)
+	Record ApiKeyRecord `json:"record"`           // The key record
+}
+
+// ApiKeysResponse message is returned for list of registered API keys
+// @Data
+type ApiKeysResponse struct {
+	BaseRestResponse
+	List []*ApiKeyRecord `json:"list"` // The key records
+}
+
+// ApiKeysEndPoint is a ready-made endpoint for managing the registered API keys (see WithApiKeyStore)
+// @Path: /api-keys (configurable)
+// @ResourceGroup: API Keys
+type ApiKeysEndPoint struct {
+	BaseEndPoint
+	path string
+}
+
+// NewApiKeysEndPoint factory method, the entries are: GET {path}, GET {path}/:kid, POST {path} and DELETE {path}/:kid
+// Listing requires the apikeys:read permission, creating and revoking requires the apikeys:write permission
+func NewApiKeysEndPoint(path string) RestEndpoint {
+	return &ApiKeysEndPoint{path: path}
+}
+
+// Path returns the base path of the endpoint
+func (e *ApiKeysEndPoint) Path() string {
+	return e.path
+}
+
+// ResourceGroup returns the API documentation group of the endpoint
+func (e *ApiKeysEndPoint) ResourceGroup() string {
+	return "API Keys"
+}
+
+// RestEntries provide REST methods configuration
+func (e *ApiKeysEndPoint) RestEntries() []RestEntry {
+	return []RestEntry{
+		{Method: http.MethodGet, Path: "", Handler: Handle(e.list), Permissions: []string{"apikeys:read"},
+			Summary: "List the registered API keys", Request: ListApiKeysRequest{}, Response: ApiKeysResponse{}},
+		{Method: http.MethodGet, Path: "/:kid", Handler: Handle(e.get), Permissions: []string{"apikeys:read"},
+			Summary: "Get the registered API key", Request: ApiKeyIdRequest{}, Response: ApiKeyResponse{}},
+		{Method: http.MethodPost, Path: "", Handler: Handle(e.create), Permissions: []string{"apikeys:write"},
+			Summary: "Issue and register a new API key", Request: CreateApiKeyRequest{}, Response: ApiKeyResponse{}},
+		{Method: http.MethodDelete, Path: "/:kid", Handler: Handle(e.revoke), Permissions: []string{"apikeys:write"},
+			Summary: "Revoke the registered API key", Request: ApiKeyIdRequest{}, Response: ActionResponse{}},
+	}
+}
+
+// endregion
+
+// region Endpoint REST handlers ---------------------------------------------------------------------------------------
+
+// List the registered API keys
+func (e *ApiKeysEndPoint) list(c *gin.Context, req ListApiKeysRequest) (*ApiKeysResponse, error) {
+	store, err := e.store(c)
+	if err != nil {
+		return nil, err
+	}
+	list, err := store.List(req.AppName)
+	if err != nil {
+		return nil, apiKeyStoreError(c, err)
+	}
+	return &ApiKeysResponse{List: list}, nil
+}
+
+// Get the registered API key
+func (e *ApiKeysEndPoint) get(c *gin.Context, req ApiKeyIdRequest) (*ApiKeyResponse, error) {
+	record, err := e.record(c, req.KeyId)
+	if err != nil {
+		return nil, err
+	}
+	return &ApiKeyResponse{Record: *record}, nil
+}
+
+// Issue and register a new API key
+func (e *ApiKeysEndPoint) create(c *gin.Context, req CreateApiKeyRequest) (*ApiKeyResponse, error) {
+	store, err := e.store(c)
+	if err != nil {
+		return nil, err
+	}
+
+	tu := contextTokenUtils(c)
+	apiKey, err := tu.CreateScopedApiKey(req.AppName, time.Duration(req.ExpiresIn)*time.Second, req.Scopes...)
+	if err != nil {
+		logger.Error("failed to create API key: %v", err)
+		return nil, NewInternalError("failed to create API key")
+	}
+	info, err := tu.ParseApiKey(apiKey)
+	if err != nil {
+		logger.Error("failed to create API key: %v", err)
+		return nil, NewInternalError("failed to create API key")
+	}
+
+	record := &ApiKeyRecord{
+		KeyId:          info.KeyId,
+		AppName:        info.AppName,
+		Description:    req.Description,
+		Scopes:         info.Scopes,
+		AllowedOrigins: req.AllowedOrigins,
+		AllowedIPs:     req.AllowedIPs,
+		RateLimitTier:  req.RateLimitTier,
+		CreatedAt:      info.IssuedAt,
+		ExpiresAt:      info.ExpiresAt,
+	}
+	if err = validateApiKeyRecord(record); err != nil {
+		return nil, err
+	}
+	if err = store.Save(record); err != nil {
+		return nil, apiKeyStoreError(c, err)
+	}
+	return &ApiKeyResponse{ApiKey: apiKey, Record: *record}, nil
+}
+
+// Revoke the registered API key
+func (e *ApiKeysEndPoint) revoke(c *gin.Context, req ApiKeyIdRequest) (*ActionResponse, error) {
+	if _, err := e.record(c, req.KeyId); err != nil {
+		return nil, err
+	}
+	store, _ := e.store(c)
+	if err := store.Revoke(req.KeyId, time.Now()); err != nil {
+		return nil, apiKeyStoreError(c, err)
+	}
+	return NewActionResponse(req.KeyId, "revoked"), nil
+}
+
+// Get the API key store of the server
+func (e *ApiKeysEndPoint) store(c *gin.Context) (IApiKeyStore, error) {
+	if s := contextServer(c); s != nil && s.apiKeys != nil {
+		return s.apiKeys, nil
+	}
+	return nil, NewInternalError("API key store is not configured")
+}
+
+// Get the registered API key record
+func (e *ApiKeysEndPoint) record(c *gin.Context, keyId string) (*ApiKeyRecord, error) {
+	store, err := e.store(c)
+	if err != nil {
+		return nil, err
+	}
+	record, err := store.Get(keyId)
+	if err != nil {
+		return nil, apiKeyStoreError(c, err)
+	}
+	if record == nil {
+		return nil, NewNotFoundError("API key not registered: %s", keyId)
+	}
+	return record, nil
+}
+
+// endregion
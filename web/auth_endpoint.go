@@ -0,0 +1,104 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	. "github.com/go-yaaf/yaaf-common-net/model"
+)
+
+// region Endpoint structure and factory method ------------------------------------------------------------------------
+
+// RefreshTokenRequest is the request to exchange refresh token for a new token pair
+// @Data
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"` // The refresh token
+}
+
+// LogoutRequest is the request to revoke the access token (X-ACCESS-TOKEN or Authorization header) and refresh token
+// @Data
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken"` // The refresh token to revoke (optional)
+}
+
+// TokenPairResponse message is returned for issued token pair
+// @Data
+type TokenPairResponse struct {
+	BaseRestResponse
+	TokenPair
+}
+
+// AuthEndPoint is a ready-made endpoint for the refresh token flow
+// @Path: /auth (configurable)
+// @ResourceGroup: Authentication
+type AuthEndPoint struct {
+	BaseEndPoint
+	path string
+}
+
+// NewAuthEndPoint factory method, the entries are: POST {path}/refresh and POST {path}/logout
+// Token pairs are issued by the application login flow using TokenUtils().CreateTokenPair
+func NewAuthEndPoint(path string) RestEndpoint {
+	return &AuthEndPoint{path: path}
+}
+
+// Path returns the base path of the endpoint
+func (e *AuthEndPoint) Path() string {
+	return e.path
+}
+
+// ResourceGroup returns the API documentation group of the endpoint
+func (e *AuthEndPoint) ResourceGroup() string {
+	return "Authentication"
+}
+
+// RestEntries provide REST methods configuration
+func (e *AuthEndPoint) RestEntries() []RestEntry {
+	return []RestEntry{
+		{Method: http.MethodPost, Path: "/refresh", Handler: Handle(e.refresh), Skip: TOKEN,
+			Summary: "Exchange refresh token for a new token pair", Request: RefreshTokenRequest{}, Response: TokenPairResponse{}},
+		{Method: http.MethodPost, Path: "/logout", Handler: Handle(e.logout), Skip: TOKEN,
+			Summary: "Revoke the access token and refresh token", Request: LogoutRequest{}, Response: ActionResponse{}},
+	}
+}
+
+// endregion
+
+// region Endpoint REST handlers ---------------------------------------------------------------------------------------
+
+// Exchange refresh token for a new token pair (the refresh token is revoked)
+func (e *AuthEndPoint) refresh(c *gin.Context, req RefreshTokenRequest) (*TokenPairResponse, error) {
+	pair, err := contextTokenUtils(c).RefreshToken(req.RefreshToken)
+	if err != nil {
+		return nil, NewUnauthorizedError("invalid refresh token: %s", err.Error())
+	}
+	return &TokenPairResponse{TokenPair: *pair}, nil
+}
+
+// Revoke the access token and refresh token
+func (e *AuthEndPoint) logout(c *gin.Context, req LogoutRequest) (*ActionResponse, error) {
+	tu := contextTokenUtils(c)
+
+	accessToken := c.GetHeader("X-ACCESS-TOKEN")
+	if len(accessToken) == 0 {
+		accessToken = bearerToken(c)
+	}
+	if len(accessToken) == 0 && len(req.RefreshToken) == 0 {
+		return nil, NewBadRequestError("no token to revoke")
+	}
+
+	if len(accessToken) > 0 {
+		if err := tu.RevokeToken(accessToken); err != nil {
+			return nil, NewUnauthorizedError("invalid access token: %s", err.Error())
+		}
+	}
+	if len(req.RefreshToken) > 0 {
+		if err := tu.RevokeToken(req.RefreshToken); err != nil {
+			return nil, NewUnauthorizedError("invalid refresh token: %s", err.Error())
+		}
+	}
+	return NewActionResponse("", "logged out"), nil
+}
+
+// endregion
@@ -0,0 +1,324 @@
+package web
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-yaaf/yaaf-common/entity"
+
+	. "github.com/go-yaaf/yaaf-common-net/model"
+)
+
+// region Authenticator interface --------------------------------------------------------------------------------------
+
+// IAuthenticator validates the credentials of a REST request
+// Authenticators are chained per endpoint group, all the authenticators in the chain must accept the request
+type IAuthenticator interface {
+	Name() string                      // Authenticator name
+	SkipFlag() int                     // RestEntry.Skip flag that bypasses this authenticator (0 for never)
+	Authenticate(c *gin.Context) error // Validate the request credentials, return error to reject the request
+}
+
+// IDocumentedAuthenticator is an optional interface of IAuthenticator to document its credentials in the OpenAPI document
+type IDocumentedAuthenticator interface {
+	SecurityScheme() (string, *OpenAPISecurityScheme) // Security scheme name and definition
+}
+
+// IAuthenticatedEndpoint is an optional interface of RestEndpoint to override the server authenticators chain
+type IAuthenticatedEndpoint interface {
+	Authenticators() []IAuthenticator // List of authenticators for this endpoint group
+}
+
+// SetTokenData stores the authenticated subject token data in the request context (available via GetTokenData)
+func SetTokenData(c *gin.Context, td *TokenData) {
+	c.Set(tokenDataContextKey, td)
+}
+
+// Get the authenticated subject token data from the request context
+func contextTokenData(c *gin.Context) *TokenData {
+	if v, ok := c.Get(tokenDataContextKey); ok {
+		if td, ok := v.(*TokenData); ok {
+			return td
+		}
+	}
+	return nil
+}
+
+// Check if the authenticator skip flag is covered by the route skip flag
+func isSkipped(mask, flag int) bool {
+	return mask > 0 && flag&mask == mask
+}
+
+// endregion
+
+// region API Key authenticator ----------------------------------------------------------------------------------------
+
+// ApiKeyAuthenticator validates the API key provided in the X-API-KEY header
+type ApiKeyAuthenticator struct {
+	appName string
+}
+
+// NewApiKeyAuthenticator creates API key authenticator, the application name extracted from the key must match the
+// provided name (if empty, the server application name is used, see WithAppName)
+func NewApiKeyAuthenticator(appName string) IAuthenticator {
+	return &ApiKeyAuthenticator{appName: appName}
+}
+
+// Name returns the authenticator name
+func (a *ApiKeyAuthenticator) Name() string { return "api-key" }
+
+// SkipFlag returns the skip flag of this authenticator
+func (a *ApiKeyAuthenticator) SkipFlag() int { return APIKEY }
+
+// SecurityScheme returns the OpenAPI security scheme of the X-API-KEY header
+func (a *ApiKeyAuthenticator) SecurityScheme() (string, *OpenAPISecurityScheme) {
+	return "ApiKey", &OpenAPISecurityScheme{Type: "apiKey", In: "header", Name: "X-API-KEY"}
+}
+
+// Authenticate validates the API key and the scopes required by the entry (see RestEntry.Scopes)
+func (a *ApiKeyAuthenticator) Authenticate(c *gin.Context) error {
+
+	restPath := strings.ToLower(c.Request.URL.Path)
+	appName := a.appName
+	if len(appName) == 0 {
+		if s := contextServer(c); s != nil {
+			appName = s.appName
+		}
+	}
+
+	// Parse API KEY and check if app name should be valid
+	info, err := contextTokenUtils(c).ParseApiKey(c.GetHeader("X-API-KEY"))
+	if err != nil {
+		return NewForbiddenError("invalid API key for path: %s", restPath)
+	} else if len(appName) > 0 && appName != info.AppName {
+		return NewForbiddenError("invalid API key for path: %s", restPath)
+	}
+
+	// Check the registered key record (revocation, allowed origins and IP ranges)
+	if s := contextServer(c); s != nil && s.apiKeys != nil {
+		if err = s.checkApiKeyRecord(c, info); err != nil {
+			return err
+		}
+	}
+
+	// Check the scopes required by the entry
+	if ri := GetRouteInfo(c); ri != nil {
+		for _, scope := range ri.Entry.Scopes {
+			if !permissionGranted(info.Scopes, scope) {
+				return NewForbiddenError("API key scope %s is required for path: %s", scope, restPath)
+			}
+		}
+	}
+	c.Set(apiKeyContextKey, info)
+	return nil
+}
+
+// Get the validated API key info from the request context
+func contextApiKeyInfo(c *gin.Context) *ApiKeyInfo {
+	if v, ok := c.Get(apiKeyContextKey); ok {
+		if info, ok := v.(*ApiKeyInfo); ok {
+			return info
+		}
+	}
+	return nil
+}
+
+// endregion
+
+// region Access Token authenticators ----------------------------------------------------------------------------------
+
+// TokenAuthenticator validates JWT access token extracted from the request
+type TokenAuthenticator struct {
+	name       string                      // Authenticator name
+	extract    func(c *gin.Context) string // Extract the token from the request
+	renew      bool                        // Renew the token and return it in the X-ACCESS-TOKEN response header
+	schemeName string                      // OpenAPI security scheme name
+	scheme     *OpenAPISecurityScheme      // OpenAPI security scheme
+}
+
+// NewTokenAuthenticator creates access token authenticator using the X-ACCESS-TOKEN header
+// A renewed token (with extended expiration) is returned in the X-ACCESS-TOKEN response header (see WithTokenRenewal)
+func NewTokenAuthenticator() IAuthenticator {
+	return &TokenAuthenticator{
+		name:       "access-token",
+		extract:    func(c *gin.Context) string { return c.GetHeader("X-ACCESS-TOKEN") },
+		renew:      true,
+		schemeName: "AccessToken",
+		scheme:     &OpenAPISecurityScheme{Type: "apiKey", In: "header", Name: "X-ACCESS-TOKEN"},
+	}
+}
+
+// NewBearerAuthenticator creates access token authenticator using the Authorization: Bearer header
+func NewBearerAuthenticator() IAuthenticator {
+	return &TokenAuthenticator{
+		name:       "bearer",
+		extract:    bearerToken,
+		schemeName: "Bearer",
+		scheme:     &OpenAPISecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+	}
+}
+
+// NewCookieAuthenticator creates access token authenticator using the provided session cookie
+func NewCookieAuthenticator(cookieName string) IAuthenticator {
+	return &TokenAuthenticator{
+		name: "cookie",
+		extract: func(c *gin.Context) string {
+			if cookie, err := c.Cookie(cookieName); err == nil {
+				return cookie
+			}
+			return ""
+		},
+		schemeName: "Cookie",
+		scheme:     &OpenAPISecurityScheme{Type: "apiKey", In: "cookie", Name: cookieName},
+	}
+}
+
+// Extract the token from the Authorization: Bearer header
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+// Name returns the authenticator name
+func (a *TokenAuthenticator) Name() string { return a.name }
+
+// SkipFlag returns the skip flag of this authenticator
+func (a *TokenAuthenticator) SkipFlag() int { return TOKEN }
+
+// SecurityScheme returns the OpenAPI security scheme of the token location (header, bearer or cookie)
+func (a *TokenAuthenticator) SecurityScheme() (string, *OpenAPISecurityScheme) {
+	return a.schemeName, a.scheme
+}
+
+// Authenticate validates the access token and stores the token data in the request context
+func (a *TokenAuthenticator) Authenticate(c *gin.Context) error {
+
+	restPath := strings.ToLower(c.Request.URL.Path)
+	token := a.extract(c)
+	if len(token) == 0 {
+		return NewUnauthorizedError("invalid auth token for path: %s", restPath)
+	}
+
+	tu := contextTokenUtils(c)
+	td, err := tu.ParseToken(token)
+	if err != nil {
+		return NewUnauthorizedError("invalid auth token for path: %s, error parsing token: %s", restPath, err.Error())
+	}
+	SetTokenData(c, td)
+
+	// Rewrite new token with new expiration time (the access token time to live), keeping the token ID
+	if s := contextServer(c); a.renew && (s == nil || s.tokenRenewal) {
+		renewed := *td
+		if renewed.ExpiresIn > 0 {
+			renewed.ExpiresIn = int64(entity.Now()) + tu.AccessTokenTTL().Milliseconds()
+		}
+		if newToken, er := tu.CreateToken(&renewed); er == nil {
+			c.Header("X-ACCESS-TOKEN", newToken)
+		}
+	}
+	return nil
+}
+
+// endregion
+
+// region Basic authenticator ------------------------------------------------------------------------------------------
+
+// BasicCredentialsValidator validates username and password and returns the authenticated subject token data
+type BasicCredentialsValidator func(username, password string) (*TokenData, error)
+
+// BasicAuthenticator validates HTTP Basic authentication credentials
+type BasicAuthenticator struct {
+	validate BasicCredentialsValidator
+}
+
+// NewBasicAuthenticator creates HTTP Basic authenticator using the provided credentials validator
+func NewBasicAuthenticator(validator BasicCredentialsValidator) IAuthenticator {
+	return &BasicAuthenticator{validate: validator}
+}
+
+// Name returns the authenticator name
+func (a *BasicAuthenticator) Name() string { return "basic" }
+
+// SkipFlag returns the skip flag of this authenticator
+func (a *BasicAuthenticator) SkipFlag() int { return TOKEN }
+
+// SecurityScheme returns the OpenAPI security scheme of HTTP Basic authentication
+func (a *BasicAuthenticator) SecurityScheme() (string, *OpenAPISecurityScheme) {
+	return "Basic", &OpenAPISecurityScheme{Type: "http", Scheme: "basic"}
+}
+
+// Authenticate validates the credentials and stores the token data in the request context
+func (a *BasicAuthenticator) Authenticate(c *gin.Context) error {
+
+	restPath := strings.ToLower(c.Request.URL.Path)
+	username, password, ok := c.Request.BasicAuth()
+	if !ok {
+		c.Header("WWW-Authenticate", `Basic realm="restricted"`)
+		return NewUnauthorizedError("missing basic credentials for path: %s", restPath)
+	}
+
+	td, err := a.validate(username, password)
+	if err != nil {
+		return NewUnauthorizedError("invalid basic credentials for path: %s", restPath)
+	}
+	if td != nil {
+		SetTokenData(c, td)
+	}
+	return nil
+}
+
+// endregion
+
+// region Composite authenticator --------------------------------------------------------------------------------------
+
+// AnyOfAuthenticator accepts the request if any of its authenticators accepts it
+type AnyOfAuthenticator struct {
+	authenticators []IAuthenticator
+}
+
+// AnyOf creates authenticator that accepts the request if any of the provided authenticators accepts it
+// (e.g. X-ACCESS-TOKEN header or Authorization: Bearer header)
+func AnyOf(authenticators ...IAuthenticator) IAuthenticator {
+	return &AnyOfAuthenticator{authenticators: authenticators}
+}
+
+// Name returns the authenticator name
+func (a *AnyOfAuthenticator) Name() string {
+	names := make([]string, 0, len(a.authenticators))
+	for _, auth := range a.authenticators {
+		names = append(names, auth.Name())
+	}
+	return "any-of(" + strings.Join(names, ",") + ")"
+}
+
+// SkipFlag returns the skip flags shared by all the authenticators
+func (a *AnyOfAuthenticator) SkipFlag() int {
+	if len(a.authenticators) == 0 {
+		return 0
+	}
+	flag := a.authenticators[0].SkipFlag()
+	for _, auth := range a.authenticators[1:] {
+		flag &= auth.SkipFlag()
+	}
+	return flag
+}
+
+// Authenticate returns nil on the first authenticator accepting the request, otherwise returns the first error
+func (a *AnyOfAuthenticator) Authenticate(c *gin.Context) (err error) {
+	for _, auth := range a.authenticators {
+		if er := auth.Authenticate(c); er == nil {
+			return nil
+		} else if err == nil {
+			err = er
+		}
+	}
+	if err == nil {
+		err = NewUnauthorizedError("no authenticator configured for path: %s", strings.ToLower(c.Request.URL.Path))
+	}
+	return err
+}
+
+// endregion
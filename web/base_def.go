@@ -8,11 +8,19 @@ import (
 
 // RestEntry represent a single HTTP REST call
 type RestEntry struct {
-	Path    string          // Rest method path
-	Method  string          // HTTP method verb
-	Handler gin.HandlerFunc // Handler function
-	Skip    int             // Skip validation
-	Role    int             // Role flags
+	Path        string            // Rest method path
+	Method      string            // HTTP method verb
+	Handler     gin.HandlerFunc   // Handler function
+	Skip        int               // Skip validation
+	Role        int               // Role flags
+	Permissions []string          // Required permissions, e.g. heroes:write (see WithPermissionResolver)
+	Checks      []AccessCheck     // Attribute based access checks, e.g. MatchAccountParam("accountId")
+	Scopes      []string          // Required API key scopes, e.g. heroes:read (see TokenUtils().CreateScopedApiKey)
+	RateLimit   *RateLimitPolicy  // Rate limit policy (default: the server policy, see WithRateLimit)
+	Middlewares []gin.HandlerFunc // Entry middlewares, invoked after the endpoint middlewares (see IMiddlewareEndpoint)
+	Summary     string            // Short description of the entry (for API documentation)
+	Request     any               // Request type sample, e.g. HeroRequest{} (for API documentation)
+	Response    any               // Response type sample, e.g. EntityResponse[*Hero]{} (for API documentation)
 }
 
 // ID returns the unique ID of the REST entry
@@ -25,3 +33,17 @@ type RestEndpoint interface {
 	Path() string             // Rest method path
 	RestEntries() []RestEntry // List of REST entries
 }
+
+// IMiddlewareEndpoint is an optional interface of RestEndpoint providing middlewares for all the endpoint entries
+//
+// Middlewares order: server middlewares (CORS, cache, recovery, authentication, API version), entry rate limiter,
+// endpoint middlewares, entry middlewares (RestEntry.Middlewares) and the entry handler.
+// CORS preflight (OPTIONS) requests are handled by the server middlewares only
+type IMiddlewareEndpoint interface {
+	Middlewares() []gin.HandlerFunc // List of middlewares for the endpoint entries
+}
+
+// IDocumentedEndpoint is an optional interface of RestEndpoint providing API documentation attributes
+type IDocumentedEndpoint interface {
+	ResourceGroup() string // Resource group name (used as the OpenAPI tag of the endpoint entries)
+}
@@ -1,6 +1,7 @@
 package web
 
 import (
+	"crypto/x509"
 	"fmt"
 	"reflect"
 	"strconv"
@@ -13,7 +14,6 @@ import (
 	"github.com/go-yaaf/yaaf-common/utils/collections"
 
 	. "github.com/go-yaaf/yaaf-common-net/model"
-	"github.com/go-yaaf/yaaf-common-net/utils"
 )
 
 const (
@@ -27,8 +27,14 @@ type BaseEndPoint struct{}
 
 // GetTokenData extract security token data from Authorization header
 func (b *BaseEndPoint) GetTokenData(c *gin.Context) *TokenData {
+
+	// Token already validated by the server
+	if td := contextTokenData(c); td != nil {
+		return td
+	}
+
 	token := c.GetHeader("X-ACCESS-TOKEN")
-	if td, err := utils.TokenUtils().ParseToken(token); err != nil {
+	if td, err := contextTokenUtils(c).ParseToken(token); err != nil {
 		//_ = c.AbortWithError(http.StatusForbidden, fmt.Errorf("invalid access token"))
 		return nil
 	} else {
@@ -52,6 +58,16 @@ func (b *BaseEndPoint) GetTimezoneOffset(c *gin.Context) int {
 	}
 }
 
+// GetApiKeyInfo returns the validated API key info (application name, key ID and scopes), or nil if not validated
+func (b *BaseEndPoint) GetApiKeyInfo(c *gin.Context) *ApiKeyInfo {
+	return contextApiKeyInfo(c)
+}
+
+// GetApiKeyRecord returns the registered API key record, or nil if no API key store is configured (see WithApiKeyStore)
+func (b *BaseEndPoint) GetApiKeyRecord(c *gin.Context) *ApiKeyRecord {
+	return contextApiKeyRecord(c)
+}
+
 // ResolveRemoteIp extract remote ip from HTTP header X-Forwarded-For
 func (b *BaseEndPoint) ResolveRemoteIp(c *gin.Context) (ip string) {
 	if ip = c.GetHeader("X-Forwarded-For"); len(ip) == 0 {
@@ -60,6 +76,23 @@ func (b *BaseEndPoint) ResolveRemoteIp(c *gin.Context) (ip string) {
 	return
 }
 
+// GetClientCertificate returns the verified client certificate (mutual TLS), or nil if not provided
+func (b *BaseEndPoint) GetClientCertificate(c *gin.Context) *x509.Certificate {
+	if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 || len(c.Request.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return c.Request.TLS.VerifiedChains[0][0]
+}
+
+// GetClientSubject returns the subject distinguished name of the verified client certificate (mutual TLS)
+// Returns empty string if no verified client certificate was provided
+func (b *BaseEndPoint) GetClientSubject(c *gin.Context) string {
+	if cert := b.GetClientCertificate(c); cert != nil {
+		return cert.Subject.String()
+	}
+	return ""
+}
+
 // GetParamAsString extract parameter value from query string as string
 func (b *BaseEndPoint) GetParamAsString(c *gin.Context, paramName string, defaultValue string) string {
 	// First try query params, then try path param, then return default
@@ -0,0 +1,214 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// region CORS policy --------------------------------------------------------------------------------------------------
+
+// CORSPolicy defines the Cross-Origin Resource Sharing policy of the server
+type CORSPolicy struct {
+	AllowedOrigins   []string      // Allowed origins: exact ("https://app.example.com"), wildcard ("https://*.example.com"), any ("*") or regex ("regex:^https://.*$")
+	AllowedMethods   []string      // Allowed HTTP methods for preflight requests
+	AllowedHeaders   []string      // Allowed request headers for preflight requests
+	ExposedHeaders   []string      // Response headers exposed to the browser
+	AllowCredentials bool          // Allow cookies and credentials (not allowed with any origin "*")
+	MaxAge           time.Duration // How long the preflight response can be cached
+}
+
+// DefaultCORSPolicy returns the default policy: any origin, common methods and the headers used by the server
+func DefaultCORSPolicy() CORSPolicy {
+	return CORSPolicy{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Content-Type", "Authorization", "X-API-KEY", "X-ACCESS-TOKEN", "X-TIMEZONE", "X-Requested-With"},
+		ExposedHeaders: []string{"X-API-KEY", "X-ACCESS-TOKEN", "X-TIMEZONE", "Content-Disposition", "Content-Filename"},
+		MaxAge:         24 * time.Hour,
+	}
+}
+
+// WithCORS sets the CORS policy applied to the REST endpoints (including preflight OPTIONS requests)
+// Headers set by WithHeader override the headers generated by the policy
+func (s *Server) WithCORS(policy CORSPolicy) *Server {
+	s.cors = compileCORSPolicy(policy)
+	return s
+}
+
+// endregion
+
+// region CORS policy implementation -----------------------------------------------------------------------------------
+
+// originMatcher checks if the origin is allowed
+type originMatcher func(origin string) bool
+
+// corsPolicy is a compiled CORS policy
+type corsPolicy struct {
+	anyOrigin        bool
+	matchers         []originMatcher
+	allowMethods     string
+	allowHeaders     string
+	exposeHeaders    string
+	allowCredentials bool
+	maxAge           string
+}
+
+// compile CORS policy origin patterns, panics on invalid regular expression or credentials allowed for any origin
+func compileCORSPolicy(policy CORSPolicy) *corsPolicy {
+	cp := &corsPolicy{
+		allowMethods:     strings.Join(policy.AllowedMethods, ","),
+		allowHeaders:     strings.Join(policy.AllowedHeaders, ", "),
+		exposeHeaders:    strings.Join(policy.ExposedHeaders, ", "),
+		allowCredentials: policy.AllowCredentials,
+	}
+	if policy.MaxAge > 0 {
+		cp.maxAge = strconv.Itoa(int(policy.MaxAge.Seconds()))
+	}
+
+	anyOrigin, matchers, err := compileOrigins(policy.AllowedOrigins)
+	if err != nil {
+		panic(fmt.Errorf("invalid CORS origin pattern: %s", err.Error()))
+	}
+	if anyOrigin && policy.AllowCredentials {
+		panic(fmt.Errorf("invalid CORS policy: credentials can not be allowed for any origin"))
+	}
+	cp.anyOrigin, cp.matchers = anyOrigin, matchers
+	return cp
+}
+
+// compile origin patterns: exact, wildcard, any ("*") or regex ("regex:...")
+func compileOrigins(origins []string) (anyOrigin bool, matchers []originMatcher, err error) {
+	for _, origin := range origins {
+		switch {
+		case origin == "*":
+			anyOrigin = true
+		case strings.HasPrefix(origin, "regex:"):
+			re, er := regexp.Compile(origin[len("regex:"):])
+			if er != nil {
+				return false, nil, fmt.Errorf("%s: %s", origin, er.Error())
+			}
+			matchers = append(matchers, re.MatchString)
+		case strings.Contains(origin, "*"):
+			idx := strings.Index(origin, "*")
+			prefix, suffix := strings.ToLower(origin[:idx]), strings.ToLower(origin[idx+1:])
+			matchers = append(matchers, func(o string) bool {
+				o = strings.ToLower(o)
+				return len(o) > len(prefix)+len(suffix) && strings.HasPrefix(o, prefix) && strings.HasSuffix(o, suffix)
+			})
+		default:
+			exact := strings.ToLower(origin)
+			matchers = append(matchers, func(o string) bool { return strings.ToLower(o) == exact })
+		}
+	}
+	return anyOrigin, matchers, nil
+}
+
+// Check if origin is allowed by the policy
+func (p *corsPolicy) allowed(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	for _, match := range p.matchers {
+		if match(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply the policy headers to the response, returns false if the origin is not allowed
+func (p *corsPolicy) apply(c *gin.Context, preflight bool) bool {
+
+	// The response depends on the origin unless any origin is allowed
+	h := c.Writer.Header()
+	if !p.anyOrigin {
+		h.Add("Vary", "Origin")
+	}
+
+	origin := c.GetHeader("Origin")
+	if len(origin) == 0 {
+		return true
+	}
+	if !p.allowed(origin) {
+		return false
+	}
+
+	if p.anyOrigin {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.allowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if preflight {
+		if len(p.allowMethods) > 0 {
+			h.Set("Access-Control-Allow-Methods", p.allowMethods)
+		}
+		if len(p.allowHeaders) > 0 {
+			h.Set("Access-Control-Allow-Headers", p.allowHeaders)
+		}
+		if len(p.maxAge) > 0 {
+			h.Set("Access-Control-Max-Age", p.maxAge)
+		}
+	} else if len(p.exposeHeaders) > 0 {
+		h.Set("Access-Control-Expose-Headers", p.exposeHeaders)
+	}
+	return true
+}
+
+// Get the CORS policy of the server handling the request (or the default policy)
+func contextCORSPolicy(c *gin.Context) *corsPolicy {
+	if s := contextServer(c); s != nil && s.cors != nil {
+		return s.cors
+	}
+	return defaultCORSPolicy
+}
+
+var defaultCORSPolicy = compileCORSPolicy(DefaultCORSPolicy())
+
+// endregion
+
+// region CORS handlers ------------------------------------------------------------------------------------------------
+
+// CorsOptions handles CORS preflight (OPTIONS) requests using the server CORS policy
+func CorsOptions(c *gin.Context) {
+	if !contextCORSPolicy(c).apply(c, true) {
+		c.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+// Enable CORS
+func (s *Server) corsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		preflight := c.Request.Method == http.MethodOptions
+		allowed := s.cors.apply(c, preflight)
+
+		// Add custom headers
+		for k, v := range s.headers {
+			c.Writer.Header().Set(k, v)
+		}
+
+		if preflight {
+			if !allowed {
+				c.AbortWithStatus(http.StatusForbidden)
+			} else {
+				c.AbortWithStatus(http.StatusNoContent)
+			}
+			return
+		}
+		c.Next()
+	}
+}
+
+// endregion
@@ -0,0 +1,207 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-yaaf/yaaf-common/logger"
+)
+
+// Generic error returned to the client instead of the details of internal failures
+var errInternal = errors.New("internal server error")
+
+// region HTTP errors --------------------------------------------------------------------------------------------------
+
+// HttpError is an error carrying the HTTP status code to return to the client
+type HttpError struct {
+	Status  int    // HTTP status code
+	Message string // Error message
+	Exposed bool   // Expose the message to the client also for internal failures (5xx)
+}
+
+// Error returns the error message
+func (e *HttpError) Error() string {
+	return e.Message
+}
+
+// StatusCode returns the HTTP status code of the error
+func (e *HttpError) StatusCode() int {
+	return e.Status
+}
+
+// Expose marks the message as safe to return to the client also for internal failures (5xx)
+func (e *HttpError) Expose() *HttpError {
+	e.Exposed = true
+	return e
+}
+
+// NewHttpError creates an error with the provided HTTP status code
+func NewHttpError(status int, format string, args ...any) *HttpError {
+	return &HttpError{Status: status, Message: fmt.Sprintf(format, args...)}
+}
+
+// NewBadRequestError creates an error for invalid request (HTTP 400)
+func NewBadRequestError(format string, args ...any) *HttpError {
+	return NewHttpError(http.StatusBadRequest, format, args...)
+}
+
+// NewUnauthorizedError creates an error for missing or invalid credentials (HTTP 401)
+func NewUnauthorizedError(format string, args ...any) *HttpError {
+	return NewHttpError(http.StatusUnauthorized, format, args...)
+}
+
+// NewForbiddenError creates an error for valid credentials without access rights (HTTP 403)
+func NewForbiddenError(format string, args ...any) *HttpError {
+	return NewHttpError(http.StatusForbidden, format, args...)
+}
+
+// NewNotFoundError creates an error for missing resource (HTTP 404)
+func NewNotFoundError(format string, args ...any) *HttpError {
+	return NewHttpError(http.StatusNotFound, format, args...)
+}
+
+// NewConflictError creates an error for conflict with the current state of the resource (HTTP 409)
+func NewConflictError(format string, args ...any) *HttpError {
+	return NewHttpError(http.StatusConflict, format, args...)
+}
+
+// NewTooManyRequestsError creates an error for rate limited request (HTTP 429)
+func NewTooManyRequestsError(format string, args ...any) *HttpError {
+	return NewHttpError(http.StatusTooManyRequests, format, args...)
+}
+
+// NewInternalError creates an error for unexpected server failure (HTTP 500)
+func NewInternalError(format string, args ...any) *HttpError {
+	return NewHttpError(http.StatusInternalServerError, format, args...)
+}
+
+// endregion
+
+// region Validation errors --------------------------------------------------------------------------------------------
+
+// FieldError describes a single invalid field or parameter
+type FieldError struct {
+	Field   string `json:"field"`           // Field or parameter name
+	Value   string `json:"value,omitempty"` // Provided value
+	Message string `json:"message"`         // Error description
+}
+
+// ValidationError is an aggregated error listing all the invalid fields or parameters (HTTP 400)
+type ValidationError struct {
+	Errors []FieldError
+}
+
+// Error returns the error message
+func (e *ValidationError) Error() string {
+	list := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		list = append(list, fmt.Sprintf("%s: %s", fe.Field, fe.Message))
+	}
+	return "invalid parameters: " + strings.Join(list, "; ")
+}
+
+// StatusCode returns the HTTP status code of the error
+func (e *ValidationError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// Add invalid field error
+func (e *ValidationError) Add(field, value, message string) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Value: value, Message: message})
+}
+
+// endregion
+
+// region Error rendering ----------------------------------------------------------------------------------------------
+
+// ProblemDetails is the RFC 7807 error response body (application/problem+json)
+type ProblemDetails struct {
+	Type     string       `json:"type"`               // Problem type URI (about:blank for generic HTTP errors)
+	Title    string       `json:"title"`              // Short summary of the problem type
+	Status   int          `json:"status"`             // HTTP status code
+	Detail   string       `json:"detail,omitempty"`   // Explanation of this occurrence of the problem
+	Instance string       `json:"instance,omitempty"` // Request path of this occurrence of the problem
+	Errors   []FieldError `json:"errors,omitempty"`   // Invalid fields or parameters (validation errors)
+}
+
+// ErrorRenderer writes the error response, it is used for authentication failures, panics and handler errors
+type ErrorRenderer func(c *gin.Context, status int, err error)
+
+// ProblemJSONRenderer writes the error as RFC 7807 application/problem+json body (default)
+func ProblemJSONRenderer(c *gin.Context, status int, err error) {
+	problem := ProblemDetails{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   publicError(c, status, err).Error(),
+		Instance: c.Request.URL.Path,
+	}
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		problem.Errors = ve.Errors
+	}
+	c.Header("Content-Type", "application/problem+json")
+	c.JSON(status, problem)
+}
+
+// LegacyErrorRenderer writes the error as BaseRestResponse body (error code -1)
+func LegacyErrorRenderer(c *gin.Context, status int, err error) {
+	c.JSON(status, NewErrorResponse(publicError(c, status, err)))
+}
+
+// WithErrorRenderer sets the error response renderer (default: ProblemJSONRenderer)
+func (s *Server) WithErrorRenderer(renderer ErrorRenderer) *Server {
+	s.errorRenderer = renderer
+	return s
+}
+
+// RenderError aborts the request and writes the error response using the server error renderer
+// The HTTP status is taken from the error (see HttpError), or 500 if not provided
+func RenderError(c *gin.Context, err error) {
+	renderError(c, errorStatus(err, http.StatusInternalServerError), err)
+}
+
+// Abort the request and write the error response with the provided status
+func renderError(c *gin.Context, status int, err error) {
+	renderer := ProblemJSONRenderer
+	if s := contextServer(c); s != nil && s.errorRenderer != nil {
+		renderer = s.errorRenderer
+	}
+	_ = c.Error(err)
+	c.Abort()
+	renderer(c, status, err)
+}
+
+// endregion
+
+// region Error helpers ------------------------------------------------------------------------------------------------
+
+// Get the HTTP status code of the error (if not provided, use the default status)
+func errorStatus(err error, defaultStatus int) int {
+	var sc interface{ StatusCode() int }
+	if errors.As(err, &sc) {
+		return sc.StatusCode()
+	}
+	return defaultStatus
+}
+
+// Get the error to expose to the client: internal failures (5xx) are logged and replaced by a generic error,
+// unless their message was exposed explicitly (see HttpError.Expose)
+func publicError(c *gin.Context, status int, err error) error {
+	if status < http.StatusInternalServerError {
+		return err
+	}
+	var he *HttpError
+	if errors.As(err, &he) && he.Exposed {
+		return err
+	}
+	if !errors.Is(err, errInternal) {
+		logger.Error("internal error on path: %s: %v", c.Request.URL.Path, err)
+	}
+	return errInternal
+}
+
+// endregion
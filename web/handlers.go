@@ -0,0 +1,148 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// region Typed handlers -----------------------------------------------------------------------------------------------
+
+// TypedHandlerFunc is a handler function with typed request and response
+type TypedHandlerFunc[Req any, Res any] func(c *gin.Context, req Req) (Res, error)
+
+// Handle adapts typed handler function to gin handler:
+//   - Binds the JSON body, path params (uri tag) and query params (form and param tags) into the request structure,
+//     fields without uri or form tag are not bound from the path or query params
+//   - Validates the request structure (binding tag)
+//   - Invokes the handler and returns the response as JSON (HTTP 200)
+//   - Maps binding errors to HTTP 400 and handler errors to their HTTP status (default 500), see WithErrorRenderer
+func Handle[Req any, Res any](fn TypedHandlerFunc[Req, Res]) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req Req
+		if err := bindRequest(c, &req); err != nil {
+			RenderError(c, err)
+			return
+		}
+
+		res, err := fn(c, req)
+		if err != nil {
+			RenderError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, res)
+	}
+}
+
+// Bind request body, path params and query params into the request structure and validate it
+func bindRequest(c *gin.Context, req any) error {
+
+	// Bind JSON body
+	if c.Request.Body != nil && c.Request.ContentLength != 0 {
+		if err := json.NewDecoder(c.Request.Body).Decode(req); err != nil && !errors.Is(err, io.EOF) {
+			return NewBadRequestError("invalid request body: %s", err.Error())
+		}
+	}
+
+	// Path and query params are supported for structures only (pointers are allocated)
+	target := reflect.ValueOf(req).Elem()
+	for target.Kind() == reflect.Pointer {
+		if target.IsNil() {
+			target.Set(reflect.New(target.Type().Elem()))
+		}
+		target = target.Elem()
+	}
+	if target.Kind() != reflect.Struct {
+		return nil
+	}
+	req = target.Addr().Interface()
+
+	if len(c.Params) > 0 {
+		params := make(map[string][]string, len(c.Params))
+		for _, p := range c.Params {
+			params[p.Key] = []string{p.Value}
+		}
+		if err := binding.MapFormWithTag(req, taggedValues(target.Type(), params, "uri"), "uri"); err != nil {
+			return NewBadRequestError("invalid path parameter: %s", err.Error())
+		}
+	}
+
+	if err := binding.MapFormWithTag(req, taggedValues(target.Type(), c.Request.URL.Query(), "form"), "form"); err != nil {
+		return NewBadRequestError("invalid query parameter: %s", err.Error())
+	}
+
+	// Bind param tags (see BindParams)
+	if err := bindParams(c, req); err != nil {
+		return err
+	}
+
+	if binding.Validator != nil {
+		if err := binding.Validator.ValidateStruct(req); err != nil {
+			return toValidationError(err)
+		}
+	}
+	return nil
+}
+
+// Get the values of the fields with explicit tag only: gin maps untagged fields by their name, so the path or query
+// params would override the body fields (e.g. ?Admin=true)
+func taggedValues(st reflect.Type, values map[string][]string, tag string) map[string][]string {
+	names := make(map[string]bool)
+	taggedNames(st, tag, names, make(map[reflect.Type]bool))
+
+	result := make(map[string][]string, len(values))
+	for name, value := range values {
+		if names[name] {
+			result[name] = value
+		}
+	}
+	return result
+}
+
+// Collect the names of the fields with explicit tag (nested structures included)
+func taggedNames(st reflect.Type, tag string, names map[string]bool, visited map[reflect.Type]bool) {
+	if visited[st] {
+		return
+	}
+	visited[st] = true
+
+	for i := 0; i < st.NumField(); i++ {
+		field := st.Field(i)
+		if name, ok := field.Tag.Lookup(tag); ok {
+			if name = strings.Split(name, ",")[0]; len(name) > 0 && name != "-" {
+				names[name] = true
+			}
+			continue
+		}
+		ft := field.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct {
+			taggedNames(ft, tag, names, visited)
+		}
+	}
+}
+
+// Convert struct validation errors to ValidationError listing the invalid fields
+func toValidationError(err error) error {
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return NewBadRequestError("invalid request: %s", err.Error())
+	}
+	ve := &ValidationError{}
+	for _, fe := range fieldErrors {
+		ve.Add(fe.Field(), fmt.Sprintf("%v", fe.Value()), fmt.Sprintf("failed on the '%s' rule", fe.Tag()))
+	}
+	return ve
+}
+
+// endregion
@@ -0,0 +1,42 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/go-yaaf/yaaf-common-net/utils"
+)
+
+// region JSON Web Key Set ---------------------------------------------------------------------------------------------
+
+// jwksPath is the well-known path of the published public keys
+const jwksPath = "/.well-known/jwks.json"
+
+// WithJWKSEndpoint publishes the public keys of the asymmetric signing keys at /.well-known/jwks.json
+// (see TokenUtils().RotateSigningKeyPEM), so other services can verify the issued tokens without sharing secrets
+func (s *Server) WithJWKSEndpoint() *Server {
+	s.engine.GET(jwksPath, func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, s.tokens.JWKS())
+	})
+	return s
+}
+
+// WithJWKS adds the public keys of the key set to verify tokens issued by another service
+func (s *Server) WithJWKS(set *utils.JSONWebKeySet) *Server {
+	if err := s.tokens.AddJWKS(set); err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// WithJWKSFile adds the public keys of the key set file to verify tokens issued by another service
+func (s *Server) WithJWKSFile(path string) *Server {
+	if err := s.tokens.LoadJWKSFile(path); err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// endregion
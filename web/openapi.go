@@ -0,0 +1,422 @@
+package web
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed swagger_ui.html
+var swaggerUIPage string
+
+// Swagger UI static assets (see swagger-ui/README.md)
+//
+//go:embed swagger-ui/swagger-ui.css swagger-ui/swagger-ui-bundle.js swagger-ui/swagger-initializer.js
+var swaggerUIAssets embed.FS
+
+// region OpenAPI document model ---------------------------------------------------------------------------------------
+
+// OpenAPIInfo is the general information of the API
+type OpenAPIInfo struct {
+	Title       string `json:"title"`                 // API title
+	Description string `json:"description,omitempty"` // API description
+	Version     string `json:"version"`               // API version (if empty, the server API version is used)
+}
+
+// OpenAPIDocument is the root of the OpenAPI 3 document
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components"`
+}
+
+// OpenAPIComponents holds the reusable schemas and security schemes
+type OpenAPIComponents struct {
+	Schemas         map[string]*OpenAPISchema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*OpenAPISecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// OpenAPISecurityScheme describes a security scheme (API key in header or cookie, HTTP basic or bearer)
+type OpenAPISecurityScheme struct {
+	Type         string `json:"type"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// OpenAPIOperation describes a single API operation on a path
+type OpenAPIOperation struct {
+	Tags        []string                    `json:"tags,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	OperationID string                      `json:"operationId"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security"`
+}
+
+// OpenAPIParameter describes a single operation parameter
+type OpenAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPIRequestBody describes the operation request body
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse describes a single response of an operation
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType describes the content of a request or response
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPISchema is a JSON schema of a data type
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+}
+
+// endregion
+
+// region OpenAPI server configuration ---------------------------------------------------------------------------------
+
+// docEntry is a registered REST entry with its endpoint attributes
+type docEntry struct {
+	basePath string
+	tag      string
+	auth     []IAuthenticator
+	entry    RestEntry
+}
+
+// WithOpenAPI serves the OpenAPI 3 document of the registered REST endpoints and the Swagger UI page:
+//   - <path>              : Swagger UI page
+//   - <path>/openapi.json : OpenAPI document (JSON)
+//   - <path>/openapi.yaml : OpenAPI document (YAML)
+//   - <path>/assets/      : Swagger UI static assets (embedded, no external resources are loaded)
+func (s *Server) WithOpenAPI(path string, info OpenAPIInfo) *Server {
+	s.openAPIInfo = info
+	specPath := joinPaths(path, "openapi.json")
+	assetsPath := joinPaths(path, "assets")
+
+	s.engine.GET(specPath, func(c *gin.Context) {
+		c.JSON(http.StatusOK, s.OpenAPIDocument())
+	})
+	s.engine.GET(joinPaths(path, "openapi.yaml"), func(c *gin.Context) {
+		// Convert to generic map to keep the JSON field names
+		var doc any
+		if buffer, err := json.Marshal(s.OpenAPIDocument()); err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+		} else if err = json.Unmarshal(buffer, &doc); err != nil {
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+		} else {
+			c.YAML(http.StatusOK, doc)
+		}
+	})
+	assets, _ := fs.Sub(swaggerUIAssets, "swagger-ui")
+	for _, name := range []string{"swagger-ui.css", "swagger-ui-bundle.js", "swagger-initializer.js"} {
+		s.engine.StaticFileFS(joinPaths(assetsPath, name), name, http.FS(assets))
+	}
+
+	page := strings.NewReplacer("{{SPEC_URL}}", specPath, "{{ASSETS_URL}}", assetsPath).Replace(swaggerUIPage)
+	s.engine.GET(path, func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+	})
+	return s
+}
+
+// OpenAPIDocument generates the OpenAPI 3 document of the registered REST endpoints
+func (s *Server) OpenAPIDocument() *OpenAPIDocument {
+
+	info := s.openAPIInfo
+	if len(info.Title) == 0 {
+		info.Title = s.appName
+	}
+	if len(info.Version) == 0 {
+		info.Version = s.version
+	}
+
+	doc := &OpenAPIDocument{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Paths:   make(map[string]map[string]*OpenAPIOperation),
+		Components: OpenAPIComponents{
+			Schemas:         make(map[string]*OpenAPISchema),
+			SecuritySchemes: make(map[string]*OpenAPISecurityScheme),
+		},
+	}
+
+	sg := &schemaGenerator{schemas: doc.Components.Schemas}
+	for _, de := range s.docEntries {
+		path, params := openAPIPath(joinPaths(de.basePath, de.entry.Path))
+		method := strings.ToLower(de.entry.Method)
+
+		op := &OpenAPIOperation{
+			Summary:     de.entry.Summary,
+			OperationID: operationID(method, path),
+			Parameters:  params,
+			Responses:   map[string]*OpenAPIResponse{},
+			Security:    entrySecurity(de.auth, de.entry.Skip, doc.Components.SecuritySchemes),
+		}
+		if len(de.tag) > 0 {
+			op.Tags = []string{de.tag}
+		}
+		if len(de.entry.Permissions) > 0 {
+			op.Description = "Required permissions: " + strings.Join(de.entry.Permissions, ", ")
+		}
+
+		// Request body or query parameters
+		if de.entry.Request != nil {
+			rt := reflect.TypeOf(de.entry.Request)
+			if method == "post" || method == "put" || method == "patch" {
+				op.RequestBody = &OpenAPIRequestBody{
+					Required: true,
+					Content:  map[string]*OpenAPIMediaType{"application/json": {Schema: sg.schemaOf(rt)}},
+				}
+			} else {
+				op.Parameters = append(op.Parameters, sg.queryParameters(rt)...)
+			}
+		}
+
+		// Responses
+		ok := &OpenAPIResponse{Description: "OK"}
+		if de.entry.Response != nil {
+			ok.Content = map[string]*OpenAPIMediaType{"application/json": {Schema: sg.schemaOf(reflect.TypeOf(de.entry.Response))}}
+		}
+		op.Responses["200"] = ok
+		if len(op.Security) > 0 {
+			op.Responses["401"] = &OpenAPIResponse{Description: "Unauthorized"}
+			op.Responses["403"] = &OpenAPIResponse{Description: "Forbidden"}
+		}
+
+		if _, exists := doc.Paths[path]; !exists {
+			doc.Paths[path] = make(map[string]*OpenAPIOperation)
+		}
+		doc.Paths[path][method] = op
+	}
+	return doc
+}
+
+// Convert router path to OpenAPI path (":id" -> "{id}") and extract the path parameters
+func openAPIPath(routerPath string) (string, []*OpenAPIParameter) {
+	var params []*OpenAPIParameter
+	segments := strings.Split(routerPath, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			name := seg[1:]
+			segments[i] = "{" + name + "}"
+			params = append(params, &OpenAPIParameter{Name: name, In: "path", Required: true, Schema: &OpenAPISchema{Type: "string"}})
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// Build unique operation ID from method and path
+func operationID(method, path string) string {
+	return method + nonWordChars.ReplaceAllString(path, "_")
+}
+
+// Security requirements of the authenticators chain not skipped by the entry skip flags, the security schemes of the
+// documented authenticators are added to the schemes (see IDocumentedAuthenticator)
+func entrySecurity(authenticators []IAuthenticator, skip int, schemes map[string]*OpenAPISecurityScheme) []map[string][]string {
+	requirements := []map[string][]string{{}}
+	for _, auth := range authenticators {
+		if isSkipped(auth.SkipFlag(), skip) {
+			continue
+		}
+		alternatives := securityAlternatives(auth, schemes)
+		if len(alternatives) == 0 {
+			continue
+		}
+
+		// All the authenticators are required: combine every requirement with every alternative of the authenticator
+		combined := make([]map[string][]string, 0, len(requirements)*len(alternatives))
+		for _, req := range requirements {
+			for _, name := range alternatives {
+				next := map[string][]string{name: {}}
+				for k, v := range req {
+					next[k] = v
+				}
+				combined = append(combined, next)
+			}
+		}
+		requirements = combined
+	}
+	if len(requirements[0]) == 0 {
+		return []map[string][]string{}
+	}
+	return requirements
+}
+
+// Get the security scheme names accepted by the authenticator (any of them), and add the schemes to the components
+func securityAlternatives(auth IAuthenticator, schemes map[string]*OpenAPISecurityScheme) (names []string) {
+	if anyOf, ok := auth.(*AnyOfAuthenticator); ok {
+		for _, a := range anyOf.authenticators {
+			names = append(names, securityAlternatives(a, schemes)...)
+		}
+		return names
+	}
+	if da, ok := auth.(IDocumentedAuthenticator); ok {
+		if name, scheme := da.SecurityScheme(); scheme != nil {
+			schemes[name] = scheme
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// endregion
+
+// region JSON schema generator ----------------------------------------------------------------------------------------
+
+var nonWordChars = regexp.MustCompile(`\W+`)
+var packagePrefix = regexp.MustCompile(`[\w\-./]*\.`)
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaGenerator builds JSON schemas from Go types, named structures are added to the components schemas
+type schemaGenerator struct {
+	schemas map[string]*OpenAPISchema
+}
+
+// Get the schema of the type
+func (g *schemaGenerator) schemaOf(t reflect.Type) *OpenAPISchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &OpenAPISchema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &OpenAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &OpenAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &OpenAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &OpenAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &OpenAPISchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &OpenAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &OpenAPISchema{Type: "string", Format: "byte"}
+		}
+		return &OpenAPISchema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &OpenAPISchema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if len(t.Name()) == 0 {
+			return g.objectSchema(t)
+		}
+		name := schemaName(t)
+		if _, exists := g.schemas[name]; !exists {
+			// Register placeholder first to support recursive types
+			g.schemas[name] = &OpenAPISchema{}
+			*g.schemas[name] = *g.objectSchema(t)
+		}
+		return &OpenAPISchema{Ref: "#/components/schemas/" + name}
+	default:
+		return &OpenAPISchema{}
+	}
+}
+
+// Build object schema from structure fields (embedded structures are flattened)
+func (g *schemaGenerator) objectSchema(t reflect.Type) *OpenAPISchema {
+	schema := &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, skip := jsonFieldName(field)
+		if skip {
+			continue
+		}
+
+		ft := field.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if field.Anonymous && len(name) == 0 && ft.Kind() == reflect.Struct {
+			for k, v := range g.objectSchema(ft).Properties {
+				schema.Properties[k] = v
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+		schema.Properties[name] = g.schemaOf(field.Type)
+	}
+	return schema
+}
+
+// Build query parameters from structure fields with form or param tag
+func (g *schemaGenerator) queryParameters(t reflect.Type) (params []*OpenAPIParameter) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("param")
+		if len(tag) == 0 {
+			tag = field.Tag.Get("form")
+		}
+		opts := strings.Split(tag, ",")
+		if !field.IsExported() || len(opts[0]) == 0 || opts[0] == "-" {
+			continue
+		}
+		required := len(opts) > 1 && opts[1] == "required"
+		params = append(params, &OpenAPIParameter{Name: opts[0], In: "query", Required: required, Schema: g.schemaOf(field.Type)})
+	}
+	sort.Slice(params, func(i, j int) bool { return params[i].Name < params[j].Name })
+	return
+}
+
+// Get the JSON name of the field and flag to skip it
+func jsonFieldName(field reflect.StructField) (name string, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+	return strings.Split(tag, ",")[0], false
+}
+
+// Build schema name from type name (generic type arguments are simplified, e.g. EntityResponse[*pkg.Hero] -> EntityResponse_Hero)
+func schemaName(t reflect.Type) string {
+	name := packagePrefix.ReplaceAllString(t.Name(), "")
+	name = strings.Trim(nonWordChars.ReplaceAllString(name, "_"), "_")
+	return name
+}
+
+// endregion
@@ -0,0 +1,232 @@
+package web
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-yaaf/yaaf-common/entity"
+)
+
+// region Enum registry ------------------------------------------------------------------------------------------------
+
+var enumRegistry = make(map[string]any)
+var enumRegistryLock sync.RWMutex
+
+// RegisterEnum registers enum structure by name, to be used by the enum tag of BindParams (e.g. RegisterEnum("Roles", Roles))
+func RegisterEnum(name string, enum any) {
+	enumRegistryLock.Lock()
+	defer enumRegistryLock.Unlock()
+	enumRegistry[name] = enum
+}
+
+// Get registered enum by name
+func getEnum(name string) (any, bool) {
+	enumRegistryLock.RLock()
+	defer enumRegistryLock.RUnlock()
+	enum, ok := enumRegistry[name]
+	if ok {
+		if rv := reflect.ValueOf(enum); rv.Kind() == reflect.Pointer {
+			enum = rv.Elem().Interface()
+		}
+	}
+	return enum, ok
+}
+
+// endregion
+
+// region Parameters binding -------------------------------------------------------------------------------------------
+
+var timestampType = reflect.TypeOf(entity.Timestamp(0))
+
+// BindParams fills the destination structure fields from query string (or path) parameters using struct tags:
+//   - param:"name[,required]" : the parameter name (query string first, then path), required flag is optional
+//   - default:"value"         : the default value if the parameter is not provided
+//   - enum:"Name"             : the registered enum (see RegisterEnum) to resolve values by enum name
+//
+// Supported field types: string, bool, integers, floats, entity.Timestamp (negative value is relative to now) and
+// slices of them (multiple values and comma separated values). All invalid parameters are reported in ValidationError
+func (b *BaseEndPoint) BindParams(c *gin.Context, dst any) error {
+	return bindParams(c, dst)
+}
+
+// Bind parameters to structure fields with param tag
+func bindParams(c *gin.Context, dst any) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("params destination must be a pointer to structure")
+	}
+
+	ve := &ValidationError{}
+	bindStructParams(c, rv.Elem(), ve)
+	if len(ve.Errors) > 0 {
+		return ve
+	}
+	return nil
+}
+
+// Bind parameters to structure fields (embedded structures included)
+func bindStructParams(c *gin.Context, sv reflect.Value, ve *ValidationError) {
+	st := sv.Type()
+	for i := 0; i < st.NumField(); i++ {
+		field := st.Field(i)
+		fv := sv.Field(i)
+
+		tag, hasTag := field.Tag.Lookup("param")
+		if !hasTag {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				bindStructParams(c, fv, ve)
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		opts := strings.Split(tag, ",")
+		name := opts[0]
+		required := len(opts) > 1 && opts[1] == "required"
+
+		values, found := paramValues(c, name)
+		if !found {
+			if def, ok := field.Tag.Lookup("default"); ok {
+				values = []string{def}
+			} else if required {
+				ve.Add(name, "", "parameter is required")
+				continue
+			} else {
+				continue
+			}
+		}
+
+		enum, hasEnum := any(nil), false
+		if enumName := field.Tag.Get("enum"); len(enumName) > 0 {
+			if enum, hasEnum = getEnum(enumName); !hasEnum {
+				ve.Add(name, "", fmt.Sprintf("enum %s is not registered", enumName))
+				continue
+			}
+		}
+
+		if err := setParamValue(fv, values, enum, hasEnum); err != nil {
+			ve.Add(name, strings.Join(values, ","), err.Error())
+		}
+	}
+}
+
+// Get parameter values from query string (multiple values supported) or path
+func paramValues(c *gin.Context, name string) ([]string, bool) {
+	if list, ok := c.GetQueryArray(name); ok {
+		return list, true
+	}
+	if str, ok := c.Params.Get(name); ok {
+		return []string{str}, true
+	}
+	return nil, false
+}
+
+// Set field value from parameter values
+func setParamValue(fv reflect.Value, values []string, enum any, hasEnum bool) error {
+
+	if fv.Kind() == reflect.Pointer {
+		ptr := reflect.New(fv.Type().Elem())
+		if err := setParamValue(ptr.Elem(), values, enum, hasEnum); err != nil {
+			return err
+		}
+		fv.Set(ptr)
+		return nil
+	}
+
+	if fv.Kind() == reflect.Slice {
+		items := make([]string, 0, len(values))
+		for _, v := range values {
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); len(item) > 0 {
+					items = append(items, item)
+				}
+			}
+		}
+
+		list := reflect.MakeSlice(fv.Type(), len(items), len(items))
+		var invalid []string
+		for i, item := range items {
+			if err := setScalarValue(list.Index(i), item, enum, hasEnum); err != nil {
+				invalid = append(invalid, item)
+			}
+		}
+		if len(invalid) > 0 {
+			return fmt.Errorf("invalid values: %s", strings.Join(invalid, ","))
+		}
+		fv.Set(list)
+		return nil
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+	return setScalarValue(fv, strings.TrimSpace(values[0]), enum, hasEnum)
+}
+
+// Set scalar field value from string
+func setScalarValue(fv reflect.Value, value string, enum any, hasEnum bool) error {
+
+	// Timestamp: absolute (epoch milliseconds) or relative (negative delta from now)
+	if fv.Type() == timestampType {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp")
+		}
+		fv.Set(reflect.ValueOf(relativeToAbsoluteTime(entity.Timestamp(n))))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean")
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			if !hasEnum {
+				return fmt.Errorf("invalid number")
+			}
+			// try enum name
+			en, e := getEnumValueFromName(enum, value)
+			if e != nil {
+				return fmt.Errorf("invalid enum value")
+			}
+			n = int64(en)
+		}
+		if fv.OverflowInt(n) {
+			return fmt.Errorf("number out of range")
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number")
+		}
+		if fv.OverflowUint(n) {
+			return fmt.Errorf("number out of range")
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number")
+		}
+		fv.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type: %s", fv.Type().String())
+	}
+	return nil
+}
+
+// endregion
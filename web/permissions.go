@@ -0,0 +1,147 @@
+package web
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	. "github.com/go-yaaf/yaaf-common-net/model"
+)
+
+// region Permission resolver ------------------------------------------------------------------------------------------
+
+// IPermissionResolver resolves the permissions granted to the authenticated subject
+// Permissions are strings in the format: resource:action (e.g. heroes:write), a granted permission may use wildcards:
+// heroes:* (any action on the resource) or * (any permission)
+type IPermissionResolver interface {
+	Permissions(td *TokenData) []string // List of permissions granted to the subject
+}
+
+// RolePermissionResolver resolves the permissions by the subject role flags (TokenData.SubjectRole bitmask)
+type RolePermissionResolver struct {
+	roles map[int][]string
+}
+
+// NewRolePermissionResolver creates permission resolver mapping role flags to their permissions
+// The subject is granted the permissions of all its role flags
+func NewRolePermissionResolver(roles map[int][]string) IPermissionResolver {
+	return &RolePermissionResolver{roles: roles}
+}
+
+// Permissions returns the permissions of all the subject role flags
+func (r *RolePermissionResolver) Permissions(td *TokenData) []string {
+	result := make([]string, 0)
+	if td == nil {
+		return result
+	}
+	for role, permissions := range r.roles {
+		if role > 0 && td.SubjectRole&role == role {
+			result = append(result, permissions...)
+		}
+	}
+	return result
+}
+
+// WithPermissionResolver sets the resolver of the subject permissions (required for RestEntry.Permissions)
+func (s *Server) WithPermissionResolver(resolver IPermissionResolver) *Server {
+	s.permissions = resolver
+	return s
+}
+
+// endregion
+
+// region Access checks ------------------------------------------------------------------------------------------------
+
+// AccessCheck is an attribute based access check of the authenticated subject, return error to reject the request
+type AccessCheck func(c *gin.Context, td *TokenData) error
+
+// MatchAccountParam checks that the path parameter (e.g. accountId of /accounts/:accountId) matches the subject account
+// Subjects granted any of the bypass permissions (e.g. accounts:admin) may access any account
+func MatchAccountParam(param string, bypass ...string) AccessCheck {
+	return func(c *gin.Context, td *TokenData) error {
+		if value := c.Param(param); (len(value) > 0 && value == td.AccountId) || HasPermission(c, bypass...) {
+			return nil
+		}
+		return NewForbiddenError("subject is not authorized for account: %s", c.Param(param))
+	}
+}
+
+// MatchSubjectParam checks that the path parameter (e.g. userId of /users/:userId) matches the subject ID
+// Subjects granted any of the bypass permissions (e.g. users:admin) may access any subject
+func MatchSubjectParam(param string, bypass ...string) AccessCheck {
+	return func(c *gin.Context, td *TokenData) error {
+		if value := c.Param(param); (len(value) > 0 && value == td.SubjectId) || HasPermission(c, bypass...) {
+			return nil
+		}
+		return NewForbiddenError("subject is not authorized for: %s", c.Param(param))
+	}
+}
+
+// HasPermission checks if the authenticated subject is granted any of the permissions
+func HasPermission(c *gin.Context, permissions ...string) bool {
+	granted := contextPermissions(c)
+	for _, required := range permissions {
+		if permissionGranted(granted, required) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the permissions of the authenticated subject (resolved once per request)
+func contextPermissions(c *gin.Context) []string {
+	if v, ok := c.Get(permissionsContextKey); ok {
+		if list, ok := v.([]string); ok {
+			return list
+		}
+	}
+
+	var list []string
+	if s := contextServer(c); s != nil && s.permissions != nil {
+		if td := contextTokenData(c); td != nil {
+			list = s.permissions.Permissions(td)
+		}
+	}
+	c.Set(permissionsContextKey, list)
+	return list
+}
+
+// Check if the required permission is covered by the granted permissions (including wildcards)
+func permissionGranted(granted []string, required string) bool {
+	resource, _, _ := strings.Cut(required, ":")
+	for _, p := range granted {
+		if p == required || p == "*" || p == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Check the entry permissions and access checks of the authenticated subject
+func authorize(c *gin.Context, entry *RestEntry) error {
+	if len(entry.Permissions) == 0 && len(entry.Checks) == 0 {
+		return nil
+	}
+
+	restPath := strings.ToLower(c.Request.URL.Path)
+	td := contextTokenData(c)
+	if td == nil {
+		return NewUnauthorizedError("subject not authenticated for path: %s", restPath)
+	}
+
+	granted := contextPermissions(c)
+	for _, required := range entry.Permissions {
+		if !permissionGranted(granted, required) {
+			return NewForbiddenError("permission %s is required for path: %s", required, restPath)
+		}
+	}
+
+	for _, check := range entry.Checks {
+		if err := check(c, td); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// endregion
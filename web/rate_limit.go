@@ -0,0 +1,330 @@
+package web
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-yaaf/yaaf-common/logger"
+)
+
+// region Rate limit policy --------------------------------------------------------------------------------------------
+
+// RateLimitKeyFunc extracts the client key of the request to count the requests for (empty key falls back to the IP)
+type RateLimitKeyFunc func(c *gin.Context) string
+
+// RateLimitPolicy defines token bucket rate limit of a REST entry
+type RateLimitPolicy struct {
+	Limit  int              // Number of requests allowed per period (the bucket refill rate)
+	Period time.Duration    // The refill period (default: 1 minute)
+	Burst  int              // The bucket capacity, max number of requests in a burst (default: Limit)
+	Key    RateLimitKeyFunc // The client key function (default: RateLimitByIP)
+}
+
+// RateLimitByApiKey counts the requests per application name of the X-API-KEY header
+func RateLimitByApiKey(c *gin.Context) string {
+	if info := contextApiKeyInfo(c); info != nil {
+		return "app:" + info.AppName
+	}
+	if info, err := contextTokenUtils(c).ParseApiKey(c.GetHeader("X-API-KEY")); err == nil && len(info.AppName) > 0 {
+		return "app:" + info.AppName
+	}
+	return ""
+}
+
+// RateLimitBySubject counts the requests per authenticated subject (TokenData.SubjectId)
+func RateLimitBySubject(c *gin.Context) string {
+	if td := contextTokenData(c); td != nil && len(td.SubjectId) > 0 {
+		return "sub:" + td.SubjectId
+	}
+	return ""
+}
+
+// RateLimitByAccount counts the requests per authenticated account (TokenData.AccountId)
+func RateLimitByAccount(c *gin.Context) string {
+	if td := contextTokenData(c); td != nil && len(td.AccountId) > 0 {
+		return "acc:" + td.AccountId
+	}
+	return ""
+}
+
+// RateLimitByIP counts the requests per client IP
+// The X-Forwarded-For header is used only when the request comes from a trusted proxy (see WithTrustedProxies)
+func RateLimitByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// Get the bucket capacity of the policy
+func (p *RateLimitPolicy) burst() int {
+	if p.Burst > 0 {
+		return p.Burst
+	}
+	return p.Limit
+}
+
+// Get the refill period of the policy
+func (p *RateLimitPolicy) period() time.Duration {
+	if p.Period > 0 {
+		return p.Period
+	}
+	return time.Minute
+}
+
+// endregion
+
+// region Rate limit store ---------------------------------------------------------------------------------------------
+
+// RateLimitResult is the outcome of taking a token from the client bucket
+type RateLimitResult struct {
+	Allowed    bool          // The request is allowed
+	Limit      int           // The bucket capacity
+	Remaining  int           // Number of remaining requests
+	Reset      time.Duration // Time until the bucket is full again
+	RetryAfter time.Duration // Time until the next request is allowed (when not allowed)
+}
+
+// IRateLimitStore keeps the token buckets of the clients
+// Implement this interface to share the buckets between server instances (e.g. Redis)
+type IRateLimitStore interface {
+	Take(key string, policy *RateLimitPolicy) (RateLimitResult, error) // Take a token from the bucket of the key
+}
+
+// MemoryRateLimitStore is an in-memory token buckets store (per process)
+type MemoryRateLimitStore struct {
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+// Token bucket state
+type tokenBucket struct {
+	tokens float64   // Available tokens
+	last   time.Time // Last refill time
+	full   time.Time // Time the bucket will be full again (idle buckets are removed after that time)
+}
+
+// NewMemoryRateLimitStore creates in-memory token buckets store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*tokenBucket), lastSweep: time.Now()}
+}
+
+// Take a token from the bucket of the key
+func (m *MemoryRateLimitStore) Take(key string, policy *RateLimitPolicy) (RateLimitResult, error) {
+
+	capacity := float64(policy.burst())
+	rate := float64(policy.Limit) / policy.period().Seconds()
+	if rate <= 0 {
+		return RateLimitResult{}, fmt.Errorf("invalid rate limit policy: limit must be positive")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweep(now)
+
+	bucket, ok := m.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: capacity, last: now}
+		m.buckets[key] = bucket
+	}
+
+	// Refill the bucket by the elapsed time
+	bucket.tokens = math.Min(capacity, bucket.tokens+now.Sub(bucket.last).Seconds()*rate)
+	bucket.last = now
+
+	result := RateLimitResult{Limit: int(capacity)}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - bucket.tokens) / rate)
+	}
+	result.Remaining = int(bucket.tokens)
+	result.Reset = secondsToDuration((capacity - bucket.tokens) / rate)
+	bucket.full = now.Add(result.Reset)
+	return result, nil
+}
+
+// Remove idle buckets (full buckets are equivalent to missing buckets)
+func (m *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	for key, bucket := range m.buckets {
+		if now.After(bucket.full) {
+			delete(m.buckets, key)
+		}
+	}
+	m.lastSweep = now
+}
+
+// Convert seconds to duration
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// endregion
+
+// region Rate limit middleware ----------------------------------------------------------------------------------------
+
+// WithRateLimit sets the default rate limit policy for REST entries without their own policy (see RestEntry.RateLimit)
+// Must be called before AddRESTEndpoints
+func (s *Server) WithRateLimit(policy *RateLimitPolicy) *Server {
+	s.rateLimit = policy
+	return s
+}
+
+// WithRateLimitStore replaces the in-memory token buckets store (e.g. to share the limits between server instances)
+func (s *Server) WithRateLimitStore(store IRateLimitStore) *Server {
+	s.rateLimitStore = store
+	return s
+}
+
+// WithRateLimitTiers sets the rate limit policies of the API key tiers (see ApiKeyRecord.RateLimitTier)
+// The tier limit is counted per API key across all the REST entries, in addition to the entry policy
+// Requires the API key store (see WithApiKeyStore) and must be called before AddRESTEndpoints
+func (s *Server) WithRateLimitTiers(tiers map[string]*RateLimitPolicy) *Server {
+	s.rateLimitTiers = tiers
+	return s
+}
+
+// Create rate limit handler of the REST entry, returns nil if no policy applies to the entry
+func (s *Server) rateLimiter(routeID string, policy *RateLimitPolicy) gin.HandlerFunc {
+	policy = s.entryRateLimit(policy)
+	if policy == nil && len(s.rateLimitTiers) == 0 {
+		return nil
+	}
+
+	var keyFunc RateLimitKeyFunc = RateLimitByIP
+	if policy != nil && policy.Key != nil {
+		keyFunc = policy.Key
+	}
+
+	return func(c *gin.Context) {
+
+		// The tier limit of the registered API key is shared by all the entries
+		if record := contextApiKeyRecord(c); record != nil {
+			if tier, ok := s.rateLimitTiers[record.RateLimitTier]; ok && tier.Limit > 0 {
+				if !s.takeToken(c, "tier:"+record.RateLimitTier+"|key:"+record.KeyId, tier) {
+					return
+				}
+			}
+		}
+
+		if policy != nil {
+			key := keyFunc(c)
+			if len(key) == 0 {
+				key = RateLimitByIP(c)
+			}
+			if !s.takeToken(c, routeID+"|"+key, policy) {
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// Get the rate limit policy of the REST entry (the entry policy or the default policy), nil if not limited
+func (s *Server) entryRateLimit(policy *RateLimitPolicy) *RateLimitPolicy {
+	if policy == nil {
+		policy = s.rateLimit
+	}
+	if policy != nil && policy.Limit <= 0 {
+		return nil
+	}
+	return policy
+}
+
+// Take a token from the bucket and set the rate limit headers, returns false if the request was rejected
+func (s *Server) takeToken(c *gin.Context, key string, policy *RateLimitPolicy) bool {
+	result, err := s.rateLimitStore.Take(key, policy)
+	if err != nil {
+		// Fail open: the store failure should not block the service
+		logger.Warn("rate limit store error on path: %s: %s", c.Request.URL.Path, err.Error())
+		return true
+	}
+
+	c.Header("RateLimit-Limit", fmt.Sprintf("%d", result.Limit))
+	c.Header("RateLimit-Remaining", fmt.Sprintf("%d", result.Remaining))
+	c.Header("RateLimit-Reset", fmt.Sprintf("%d", ceilSeconds(result.Reset)))
+
+	if !result.Allowed {
+		c.Header("Retry-After", fmt.Sprintf("%d", ceilSeconds(result.RetryAfter)))
+		RenderError(c, NewTooManyRequestsError("rate limit exceeded for path: %s", strings.ToLower(c.Request.URL.Path)))
+		return false
+	}
+	return true
+}
+
+// Round up duration to seconds
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// endregion
+
+// region Failed authentications limit ---------------------------------------------------------------------------------
+
+// Count the failed authentication per client IP with the entry rate limit policy, regardless of the policy key
+// (the subject or API key is unknown before authentication). When the limit is exceeded, the client IP is blocked
+// before authentication until its bucket refills
+func (s *Server) countAuthFailure(c *gin.Context, ri *RouteInfo) {
+	if ri == nil {
+		return
+	}
+	policy := s.entryRateLimit(ri.Entry.RateLimit)
+	if policy == nil {
+		return
+	}
+
+	key := authFailureKey(c, ri)
+	result, err := s.rateLimitStore.Take(key, policy)
+	if err != nil {
+		logger.Warn("rate limit store error on path: %s: %s", c.Request.URL.Path, err.Error())
+		return
+	}
+	if result.Allowed {
+		return
+	}
+
+	s.authBlockedMu.Lock()
+	defer s.authBlockedMu.Unlock()
+	now := time.Now()
+	for k, until := range s.authBlocked {
+		if now.After(until) {
+			delete(s.authBlocked, k)
+		}
+	}
+	s.authBlocked[key] = now.Add(result.RetryAfter)
+}
+
+// Check if the client IP is blocked after too many failed authentications, the request is rejected with HTTP 429
+func (s *Server) isAuthBlocked(c *gin.Context, ri *RouteInfo) bool {
+	if ri == nil {
+		return false
+	}
+	key := authFailureKey(c, ri)
+
+	s.authBlockedMu.Lock()
+	until, ok := s.authBlocked[key]
+	s.authBlockedMu.Unlock()
+
+	retryAfter := time.Until(until)
+	if !ok || retryAfter <= 0 {
+		return false
+	}
+	c.Header("Retry-After", fmt.Sprintf("%d", ceilSeconds(retryAfter)))
+	RenderError(c, NewTooManyRequestsError("too many failed authentications for path: %s", strings.ToLower(c.Request.URL.Path)))
+	return true
+}
+
+// Get the failed authentications bucket key of the route and client IP
+func authFailureKey(c *gin.Context, ri *RouteInfo) string {
+	return "auth|" + routeKey(ri.Method, ri.Path) + "|" + RateLimitByIP(c)
+}
+
+// endregion
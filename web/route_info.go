@@ -0,0 +1,61 @@
+package web
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// region Route metadata -----------------------------------------------------------------------------------------------
+
+// RouteInfo is the metadata of the REST entry matching the request
+type RouteInfo struct {
+	Method string    // HTTP method verb
+	Path   string    // Registered path template (e.g. /v1/heroes/:id)
+	Entry  RestEntry // The REST entry (skip flags, roles and policies)
+}
+
+// GetRouteInfo returns the metadata of the REST entry matching the request, or nil if no entry matches
+func GetRouteInfo(c *gin.Context) *RouteInfo {
+	if v, ok := c.Get(routeContextKey); ok {
+		if ri, ok := v.(*RouteInfo); ok {
+			return ri
+		}
+	}
+	return nil
+}
+
+// Resolve the route metadata once and inject it to the request context
+// The metadata is resolved by the route template matched by the router, so it always describes the handler that runs
+func (s *Server) routeMetadata() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ri := s.resolveRoute(c.Request.Method, c.FullPath()); ri != nil {
+			c.Set(routeContextKey, ri)
+		}
+	}
+}
+
+// Find the route metadata of the method and matched path template (REST entries first, then the skip list)
+func (s *Server) resolveRoute(method, fullPath string) *RouteInfo {
+	if len(fullPath) == 0 {
+		return nil
+	}
+	if ri, ok := s.routes[routeKey(method, fullPath)]; ok {
+		return ri
+	}
+	if flag, ok := s.skipList[fullPath]; ok {
+		return &RouteInfo{Method: method, Path: fullPath, Entry: RestEntry{Method: method, Path: fullPath, Skip: flag}}
+	}
+	return nil
+}
+
+// Add REST entry to the routes metadata, the last added entry wins for duplicate templates
+func (s *Server) addRoute(basePath string, entry RestEntry) {
+	path := joinPaths(basePath, entry.Path)
+	s.routes[routeKey(entry.Method, path)] = &RouteInfo{Method: entry.Method, Path: path, Entry: entry}
+}
+
+// Build the routes metadata key of the method and path template
+func routeKey(method, path string) string {
+	return method + " " + path
+}
+
+// endregion
@@ -1,17 +1,21 @@
 package web
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	pathpkg "path"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/go-yaaf/yaaf-common/entity"
+	"github.com/go-yaaf/yaaf-common/logger"
 
-	. "github.com/go-yaaf/yaaf-common-net/model"
 	"github.com/go-yaaf/yaaf-common-net/utils"
 )
 
@@ -21,21 +25,54 @@ var whiteList []string = []string{
 }
 
 // region REST server structure and factory method ---------------------------------------------------------------------
-var serverInst *Server = nil
+
+// Keys of values injected by the server to the request context
+const (
+	serverContextKey       = "yaaf.server"
+	tokenDataContextKey    = "yaaf.tokenData"
+	apiKeyContextKey       = "yaaf.apiKey"
+	apiKeyRecordContextKey = "yaaf.apiKeyRecord"
+	routeContextKey        = "yaaf.route"
+	permissionsContextKey  = "yaaf.permissions"
+)
 
 // Server is the main web server structure
 type Server struct {
-	engine        *gin.Engine
-	version       string
-	appName       string
-	templatesPath string
-	entries       map[string]RestEntry         // Map of REST path to REST Entries
-	registries    map[string]IWSClientRegistry // Map of web-socket groups name to web-socket client registry
-	skipList      map[string]int               // Skip validation (API KEY and TOKEN) list
-	headers       map[string]string            // Custom headers
-	proxyPath     string                       // Custom reverse proxy path
-	proxyTarget   string                       // Custom reverse proxy target
-	proxyHeaders  map[string]string            // Custom reverse proxy headers
+	engine         *gin.Engine
+	version        string
+	appName        string
+	templatesPath  string
+	entries        map[string]RestEntry         // Map of REST path to REST Entries
+	routes         map[string]*RouteInfo        // Map of HTTP method and path template to REST entry metadata
+	registries     map[string]IWSClientRegistry // Map of web-socket groups name to web-socket client registry
+	skipList       map[string]int               // Skip validation (API KEY and TOKEN) list
+	headers        map[string]string            // Custom headers
+	proxyPath      string                       // Custom reverse proxy path
+	proxyTarget    string                       // Custom reverse proxy target
+	proxyHeaders   map[string]string            // Custom reverse proxy headers
+	httpServer     *http.Server                 // Underlying HTTP server (available after Start)
+	shutdownDone   chan struct{}                // Closed when the Shutdown of the running server is completed
+	shutdownTime   time.Duration                // Max time to wait for in-flight requests on shutdown
+	trustedProxies []string                     // Proxies trusted to set the client IP headers (X-Forwarded-For)
+	tls            tlsSettings                  // TLS and mutual TLS configuration
+	tokens         *utils.TokenUtilsStruct      // Token utilities (API key and access token secrets)
+	auth           []IAuthenticator             // Default authenticators chain for REST endpoints
+	tokenRenewal   bool                         // Return renewed access token in the X-ACCESS-TOKEN response header
+	cors           *corsPolicy                  // CORS policy
+	preflight      map[string]bool              // Paths with registered CORS preflight handler
+	docEntries     []docEntry                   // Registered REST entries (for API documentation)
+	openAPIInfo    OpenAPIInfo                  // OpenAPI document information
+	errorRenderer  ErrorRenderer                // Error response renderer (default: RFC 7807 problem+json)
+	rateLimit      *RateLimitPolicy             // Default rate limit policy of REST entries
+	permissions    IPermissionResolver          // Resolver of the subject permissions
+	rateLimitStore IRateLimitStore              // Rate limit token buckets store
+	rateLimitTiers map[string]*RateLimitPolicy  // Rate limit policies of the API key tiers
+	apiKeys        IApiKeyStore                 // Registry of the issued API keys (nil for stateless keys)
+	wsKeepAlive    WSKeepAlive                  // Ping/pong keepalive of the web socket clients
+	wsSendQueue    WSSendQueue                  // Send queue configuration of the web socket clients
+	authBlocked    map[string]time.Time         // Client IPs blocked after too many failed authentications (by route)
+	authBlockedMu  sync.Mutex                   // Guards the blocked client IPs
+	mu             sync.Mutex
 }
 
 // NewWebServer Factory method
@@ -55,15 +92,23 @@ func NewWebServer() *Server {
 	//)
 
 	server := &Server{
-		engine:     engine,
-		version:    "1.0.0",
-		entries:    make(map[string]RestEntry),
-		registries: make(map[string]IWSClientRegistry),
-		skipList:   make(map[string]int),
-		headers:    make(map[string]string),
+		engine:         engine,
+		version:        "1.0.0",
+		entries:        make(map[string]RestEntry),
+		routes:         make(map[string]*RouteInfo),
+		registries:     make(map[string]IWSClientRegistry),
+		skipList:       make(map[string]int),
+		headers:        make(map[string]string),
+		shutdownTime:   30 * time.Second,
+		tokens:         utils.NewTokenUtils(),
+		tokenRenewal:   true,
+		cors:           defaultCORSPolicy,
+		preflight:      make(map[string]bool),
+		rateLimitStore: NewMemoryRateLimitStore(),
+		authBlocked:    make(map[string]time.Time),
+		wsKeepAlive:    WSKeepAlive{PingInterval: 30 * time.Second, PongTimeout: 60 * time.Second},
 	}
-	serverInst = server
-	return serverInst
+	return server
 }
 
 // WithAppName sets the application name to check after parsing the API Key
@@ -78,12 +123,32 @@ func (s *Server) WithAPIVersion(version string) *Server {
 	return s
 }
 
-// WithSecrets set token encryption secrets
+// WithSecrets set token encryption secrets for this server instance
+// Every server has its own token utilities (see TokenUtils), other configuration of the token utilities is kept
 func (s *Server) WithSecrets(apiSecret, signingKey string) *Server {
-	utils.TokenUtils().WithSecrets(apiSecret, signingKey)
+	s.tokens.WithSecrets(apiSecret, signingKey)
+	return s
+}
+
+// WithAuthenticators replaces the default authenticators chain (API key and access token) of the REST endpoints
+// All the authenticators in the chain must accept the request, use AnyOf to accept any of several authenticators
+func (s *Server) WithAuthenticators(authenticators ...IAuthenticator) *Server {
+	s.auth = authenticators
 	return s
 }
 
+// WithTokenRenewal enables or disables the renewed access token in the X-ACCESS-TOKEN response header (default: enabled)
+// Disable it when clients use the refresh token flow (see NewAuthEndPoint)
+func (s *Server) WithTokenRenewal(enabled bool) *Server {
+	s.tokenRenewal = enabled
+	return s
+}
+
+// TokenUtils returns the token utilities used by this server to create and validate API keys and access tokens
+func (s *Server) TokenUtils() *utils.TokenUtilsStruct {
+	return s.tokens
+}
+
 // WithHeader override HTTP headers for CORS manipulation
 func (s *Server) WithHeader(header, value string) *Server {
 	s.headers[header] = value
@@ -104,73 +169,20 @@ func (s *Server) WithReverseProxy(path string, target string, headers map[string
 	return s
 }
 
-// Extract SKIP validations flag from the current entry
-func (s *Server) getEntrySkipFlag(method, path string) int {
-
-	// First path: try match static path segments first
-	for k, v := range s.entries {
-		if v.Method == method {
-			parts := strings.Fields(k)
-			pt := parts[1]
-			if strings.Contains(pt, ":") {
-				continue
-			}
-			if s.matchExactPath(pt, path) {
-				return v.Skip
-			}
-		}
-	}
-
-	// Now, try match parametrized path
-	for k, v := range s.entries {
-		if v.Method == method {
-			parts := strings.Fields(k)
-			pt := parts[1]
-			if strings.Contains(pt, ":") {
-				if s.matchTemplatePath(pt, path) {
-					return v.Skip
-				}
-			}
-		}
-	}
-
-	// Now, try match skip list path
-	if flag, ok := s.skipList[path]; ok {
-		return flag
-	}
-	return 0
+// WithShutdownTimeout sets the maximum time to wait for in-flight requests when the server is stopped by context cancellation
+func (s *Server) WithShutdownTimeout(timeout time.Duration) *Server {
+	s.shutdownTime = timeout
+	return s
 }
 
-// Extract Role flags from the current entry
-func (s *Server) getEntryRoleFlag(method, path string) int {
-
-	// First path: try match static path segments first
-	for k, v := range s.entries {
-		if v.Method == method {
-			parts := strings.Fields(k)
-			pt := parts[1]
-			if strings.Contains(pt, ":") {
-				continue
-			}
-			if s.matchExactPath(pt, path) {
-				return v.Role
-			}
-		}
+// WithTrustedProxies sets the IP addresses or CIDR ranges of the proxies (e.g. load balancer) trusted to set the client
+// IP headers (X-Forwarded-For), used by the IP rate limit and the API key allowed IPs (default: no trusted proxies)
+func (s *Server) WithTrustedProxies(proxies ...string) *Server {
+	if err := s.engine.SetTrustedProxies(proxies); err != nil {
+		panic(fmt.Errorf("invalid trusted proxies: %s", err.Error()))
 	}
-
-	// Now, try match parametrized path
-	for k, v := range s.entries {
-		if v.Method == method {
-			parts := strings.Fields(k)
-			pt := parts[1]
-			if strings.Contains(pt, ":") {
-				if s.matchTemplatePath(pt, path) {
-					return v.Role
-				}
-			}
-		}
-	}
-	return 0
+	s.trustedProxies = proxies
+	return s
 }
 
 // Check if path is matching the whitelist
@@ -183,37 +195,17 @@ func (s *Server) matchWhiteList(path string) bool {
 	return false
 }
 
-// match exact path
-func (s *Server) matchExactPath(path, actual string) bool {
-	return path == actual
-}
-
-// match template path
-func (s *Server) matchTemplatePath(template, actual string) bool {
-
-	templates := strings.Split(template, "/")
-	segments := strings.Split(actual, "/")
-	if len(templates) != len(segments) {
-		return false
-	}
-	for i := 0; i < len(templates); i++ {
-		if templates[i] == segments[i] {
-			continue
-		} else if strings.HasPrefix(templates[i], ":") {
-			continue
-		} else {
-			return false
-		}
-	}
-
-	// All parts feet
-	return true
+// Start web server, this call blocks until the server is shut down (and the shutdown is completed)
+func (s *Server) Start(port int) error {
+	return s.StartWithContext(context.Background(), port)
 }
 
-// Start web server
-func (s *Server) Start(port int) error {
+// StartWithContext starts the web server and blocks until the context is canceled or Shutdown is called.
+// When the context is canceled, the server is shut down gracefully (see WithShutdownTimeout)
+// When Shutdown is called from another goroutine, it returns after the shutdown is completed
+func (s *Server) StartWithContext(ctx context.Context, port int) error {
 
-	_ = s.engine.SetTrustedProxies(nil)
+	_ = s.engine.SetTrustedProxies(s.trustedProxies)
 
 	// Proxy API requests to backend server
 	if len(s.proxyPath) > 0 {
@@ -228,7 +220,73 @@ func (s *Server) Start(port int) error {
 		port = 8080
 	}
 
-	return s.engine.Run(fmt.Sprintf(":%d", port))
+	tlsConfig, err := s.tls.build()
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Addr:      fmt.Sprintf(":%d", port),
+		Handler:   s.engine,
+		TLSConfig: tlsConfig,
+	}
+
+	done := make(chan struct{})
+	s.mu.Lock()
+	s.httpServer = srv
+	s.shutdownDone = done
+	s.mu.Unlock()
+
+	errCh := make(chan error, 1)
+	go func() {
+		if tlsConfig != nil {
+			errCh <- srv.ListenAndServeTLS(s.tls.certFile, s.tls.keyFile)
+		} else {
+			errCh <- srv.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			// Shutdown was called, wait until the connections are drained and the web socket clients are closed
+			<-done
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTime)
+		defer cancel()
+		return s.Shutdown(shutdownCtx)
+	}
+}
+
+// Shutdown gracefully stops the server: it stops accepting new connections, waits for in-flight requests to complete
+// (until the context deadline), sends close frames to all connected web-socket clients and stops the registries
+// The web-socket clients are closed in parallel, until the context deadline
+func (s *Server) Shutdown(ctx context.Context) (err error) {
+
+	s.mu.Lock()
+	srv, done := s.httpServer, s.shutdownDone
+	s.shutdownDone = nil
+	s.mu.Unlock()
+
+	// Release the Start call when the shutdown is completed
+	if done != nil {
+		defer close(done)
+	}
+
+	if srv != nil {
+		err = srv.Shutdown(ctx)
+	}
+
+	// Web socket connections are hijacked, so they are not tracked by the HTTP server
+	for _, registry := range s.registries {
+		if er := registry.Stop(ctx); er != nil && err == nil {
+			err = er
+		}
+	}
+	return err
 }
 
 // WebSocketRegistry returns the provided group's client registry
@@ -252,29 +310,84 @@ func (s *Server) AddRESTEndpoints(endpoints ...RestEndpoint) *Server {
 			group = s.engine.Group("/")
 		}
 
-		for _, entry := range ep.RestEntries() {
-			group.Handle(entry.Method, entry.Path, entry.Handler)
-			s.entries[entry.ID(group.BasePath())] = entry
+		// Endpoint may override the server authenticators chain
+		authenticators := s.authenticators()
+		if ae, ok := ep.(IAuthenticatedEndpoint); ok {
+			authenticators = ae.Authenticators()
 		}
-		group.OPTIONS("/", CorsOptions)
 
-		// Apply middlewares
+		// Apply middlewares (must be applied before the routes are registered)
 		group.Use(
-			corsMiddleware(),
+			s.serverContext(),
+			s.routeMetadata(),
+			s.corsMiddleware(),
 			disableCache(),
-			gin.CustomRecovery(customRecovery),
-			apiKeyValidator(),
-			tokenValidator(),
-			apiVersion())
+			gin.CustomRecovery(recoverPanic),
+			s.authenticate(authenticators),
+			s.apiVersion())
+
+		tag := ""
+		if de, ok := ep.(IDocumentedEndpoint); ok {
+			tag = de.ResourceGroup()
+		}
+
+		var middlewares []gin.HandlerFunc
+		if me, ok := ep.(IMiddlewareEndpoint); ok {
+			middlewares = me.Middlewares()
+		}
+
+		for _, entry := range ep.RestEntries() {
+			id := entry.ID(group.BasePath())
+			group.Handle(entry.Method, entry.Path, s.entryHandlers(id, entry, middlewares)...)
+			s.entries[id] = entry
+			s.addRoute(group.BasePath(), entry)
+			s.docEntries = append(s.docEntries, docEntry{basePath: group.BasePath(), tag: tag, auth: authenticators, entry: entry})
+		}
+
+		// Register preflight handler for every path of the group
+		s.addOptionsRoute(group, "/")
+		for _, entry := range ep.RestEntries() {
+			s.addOptionsRoute(group, entry.Path)
+		}
 	}
 	return s
 }
 
-// CorsOptions handles CORS options
-func CorsOptions(c *gin.Context) {
-	c.Writer.Header().Set("Access-Control-Allow-Methods", "GET,DELETE,POST,PUT,PATCH")
-	c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-	c.Next()
+// Build the handlers chain of the REST entry (the server middlewares are applied to the group before this chain):
+// rate limiter, endpoint middlewares, entry middlewares and the entry handler
+func (s *Server) entryHandlers(id string, entry RestEntry, middlewares []gin.HandlerFunc) []gin.HandlerFunc {
+	handlers := make([]gin.HandlerFunc, 0, len(middlewares)+len(entry.Middlewares)+2)
+	if limiter := s.rateLimiter(id, entry.RateLimit); limiter != nil {
+		handlers = append(handlers, limiter)
+	}
+	handlers = append(handlers, middlewares...)
+	handlers = append(handlers, entry.Middlewares...)
+	return append(handlers, entry.Handler)
+}
+
+// Register CORS preflight (OPTIONS) handler for the path, unless already registered
+func (s *Server) addOptionsRoute(group *gin.RouterGroup, path string) {
+	fullPath := joinPaths(group.BasePath(), path)
+	if _, exists := s.entries[fmt.Sprintf("%s %s", http.MethodOptions, fullPath)]; exists {
+		return
+	}
+	if s.preflight[fullPath] {
+		return
+	}
+	group.OPTIONS(path, CorsOptions)
+	s.preflight[fullPath] = true
+}
+
+// Join base path and relative path the same way the router does
+func joinPaths(base, relative string) string {
+	if len(relative) == 0 {
+		return base
+	}
+	final := pathpkg.Join(base, relative)
+	if strings.HasSuffix(relative, "/") && !strings.HasSuffix(final, "/") {
+		return final + "/"
+	}
+	return final
 }
 
 // AddStaticEndpoint add static file endpoint (for documentation)
@@ -289,6 +402,21 @@ func (s *Server) AddStaticFile(path, relativePath string) *Server {
 	return s
 }
 
+// WithWebSocketKeepAlive sets the ping/pong keepalive of the web socket clients (default: ping every 30 seconds and
+// disconnect after 60 seconds without pong), zero ping interval disables the keepalive
+// Must be called before AddWebSocketEndpoints
+func (s *Server) WithWebSocketKeepAlive(keepAlive WSKeepAlive) *Server {
+	s.wsKeepAlive = keepAlive
+	return s
+}
+
+// WithWebSocketSendQueue sets the send queue size and slow consumer policy of the web socket clients
+// (default: 256 messages, dropping the oldest message when full). Must be called before AddWebSocketEndpoints
+func (s *Server) WithWebSocketSendQueue(sendQueue WSSendQueue) *Server {
+	s.wsSendQueue = sendQueue
+	return s
+}
+
 // AddWebSocketEndpoints registers a single route as a web-socket listener
 func (s *Server) AddWebSocketEndpoints(endpoints ...IWSEndpointConfig) *Server {
 
@@ -306,7 +434,7 @@ func (s *Server) AddWebSocketEndpoints(endpoints ...IWSEndpointConfig) *Server {
 		}
 
 		// Create listener for each endpoint
-		listener := NewListener(registry, ep)
+		listener := NewListener(registry, ep).WithKeepAlive(s.wsKeepAlive).WithSendQueue(s.wsSendQueue)
 
 		// WS should not include API Key and Token
 		s.skipList[ep.Path()] = TOKEN
@@ -325,50 +453,23 @@ func (s *Server) AddWebSocketEndpoints(endpoints ...IWSEndpointConfig) *Server {
 
 // region Server Middlewares -------------------------------------------------------------------------------------------
 
-// Fetch API key from the header and check it
-func apiKeyValidator() gin.HandlerFunc {
+// Inject the server instance to the request context
+func (s *Server) serverContext() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		c.Set(serverContextKey, s)
+	}
+}
 
-		// Skip validator if it is not a REST request
-
-		// Skip OPTIONS
-		if c.Request.Method == "OPTIONS" {
-			c.Next()
-			return
-		}
-
-		// Skip whitelist
-		if serverInst.matchWhiteList(c.Request.URL.Path) {
-			c.Next()
-			return
-		}
-
-		// Get path and check if we need to skip API key
-		restPath := strings.ToLower(c.Request.URL.Path)
-		flag := serverInst.getEntrySkipFlag(c.Request.Method, restPath)
-		if flag&APIKEY == APIKEY {
-			c.Next()
-			return
-		}
-
-		// Extract the API KEY from the header
-		apiKey := c.GetHeader("X-API-KEY")
-
-		// Parse API KEY and check if app name should be valid
-		if appName, err := utils.TokenUtils().ParseApiKey(apiKey); err != nil {
-			_ = c.AbortWithError(http.StatusForbidden, fmt.Errorf("invalid API key for path: %s", restPath))
-		} else {
-			if len(serverInst.appName) > 0 && serverInst.appName != appName {
-				_ = c.AbortWithError(http.StatusForbidden, fmt.Errorf("invalid API key for path: %s", restPath))
-			} else {
-				c.Next()
-			}
-		}
+// Get the server authenticators chain (default: API key and access token)
+func (s *Server) authenticators() []IAuthenticator {
+	if s.auth != nil {
+		return s.auth
 	}
+	return []IAuthenticator{NewApiKeyAuthenticator(""), NewTokenAuthenticator()}
 }
 
-// Fetch and check token, after processing, renew token
-func tokenValidator() gin.HandlerFunc {
+// Run the authenticators chain and check the role guard of the entry
+func (s *Server) authenticate(authenticators []IAuthenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		// Skip OPTIONS
@@ -378,63 +479,69 @@ func tokenValidator() gin.HandlerFunc {
 		}
 
 		// Skip whitelist
-		if serverInst.matchWhiteList(c.Request.URL.Path) {
+		if s.matchWhiteList(c.Request.URL.Path) {
 			c.Next()
 			return
 		}
 
-		// Get path and check if we need to skip Access Token
+		// Get the route metadata and check which authenticators should be skipped
 		restPath := strings.ToLower(c.Request.URL.Path)
-		flag := serverInst.getEntrySkipFlag(c.Request.Method, restPath)
-		if flag&TOKEN == TOKEN {
-			c.Next()
-			return
+		ri := GetRouteInfo(c)
+		flag, roles := 0, 0
+		if ri != nil {
+			flag, roles = ri.Entry.Skip, ri.Entry.Role
 		}
 
-		// Extract the user token from the header: X-ACCESS-TOKEN
-		td := getTokenData(c)
-		if td == nil {
-			_ = c.AbortWithError(http.StatusUnauthorized, fmt.Errorf("invalid auth token for path: %s", restPath))
+		// Reject clients that exceeded the failed authentications limit before checking their credentials
+		if s.isAuthBlocked(c, ri) {
 			return
 		}
-
-		// Get path and check if role guard exists
-		roles := serverInst.getEntryRoleFlag(c.Request.Method, restPath)
-		if roles > 0 {
-			if roles&td.SubjectRole == 0 {
-				_ = c.AbortWithError(http.StatusUnauthorized, fmt.Errorf("user role not authorized for path: %s", restPath))
+		for _, auth := range authenticators {
+			if isSkipped(auth.SkipFlag(), flag) {
+				continue
+			}
+			if err := auth.Authenticate(c); err != nil {
+				s.countAuthFailure(c, ri)
+				renderError(c, errorStatus(err, http.StatusUnauthorized), err)
 				return
 			}
 		}
 
-		// Rewrite new token with new expiration time (30 minutes)
-		if td.ExpiresIn > 0 {
-			td.ExpiresIn = int64(entity.Now() + 1000*60*30)
+		// Check if role guard exists
+		if flag&TOKEN != TOKEN && roles > 0 {
+			if td := contextTokenData(c); td == nil || roles&td.SubjectRole == 0 {
+				RenderError(c, NewUnauthorizedError("user role not authorized for path: %s", restPath))
+				return
+			}
 		}
 
-		if token, err := utils.TokenUtils().CreateToken(td); err != nil {
-			return
-		} else {
-			c.Header("X-ACCESS-TOKEN", token)
+		// Check the entry permissions and access checks
+		if flag&TOKEN != TOKEN && ri != nil {
+			if err := authorize(c, &ri.Entry); err != nil {
+				renderError(c, errorStatus(err, http.StatusForbidden), err)
+				return
+			}
 		}
 		c.Next()
 	}
 }
 
-// GetTokenData extract security token data from Authorization header
-func getTokenData(c *gin.Context) *TokenData {
-
-	token := c.GetHeader("X-ACCESS-TOKEN")
-	if len(token) == 0 {
-		_ = c.AbortWithError(http.StatusUnauthorized, fmt.Errorf("invalid auth toke, length is zero"))
-		return nil
+// Get the server handling the request
+func contextServer(c *gin.Context) *Server {
+	if v, ok := c.Get(serverContextKey); ok {
+		if s, ok := v.(*Server); ok {
+			return s
+		}
 	}
-	if td, err := utils.TokenUtils().ParseToken(token); err != nil {
-		_ = c.AbortWithError(http.StatusUnauthorized, fmt.Errorf("invalid auth token, error parsing token: %s", err.Error()))
-		return nil
-	} else {
-		return td
+	return nil
+}
+
+// Get the token utilities of the server handling the request (or the process-wide instance)
+func contextTokenUtils(c *gin.Context) *utils.TokenUtilsStruct {
+	if s := contextServer(c); s != nil {
+		return s.tokens
 	}
+	return utils.TokenUtils()
 }
 
 // Add response header to disable cache
@@ -445,61 +552,15 @@ func disableCache() gin.HandlerFunc {
 }
 
 // Add custom recovery from any error
-func customRecovery(c *gin.Context, recovered any) {
-	if err, ok := recovered.(string); ok {
-		c.String(http.StatusInternalServerError, fmt.Sprintf("error: %s", err))
-	}
-	c.AbortWithStatus(http.StatusInternalServerError)
-}
-
-// Enable CORS
-func corsMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-
-		origin := c.GetHeader("Origin")
-		if origin != "" {
-			// For development, you can restrict to localhost:4200 (recommended)
-			if origin == "http://localhost:4200" || origin == "http://localhost:3000" {
-				c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
-				c.Writer.Header().Set("Vary", "Origin")
-			}
-			// If you *really* want open CORS in dev, use "*" but don't use credentials
-			// c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		}
-
-		// Add custom headers
-		for k, v := range serverInst.headers {
-			c.Writer.Header().Set(k, v)
-		}
-
-		// Add default headers
-		defaultHeaders := map[string]string{
-			"Access-Control-Allow-Origin":   "*",
-			"Access-Control-Allow-Methods":  "GET,POST,PUT,PATCH,DELETE,OPTIONS",
-			"Access-Control-Allow-Headers":  "Content-Type, Authorization, X-API-KEY, X-ACCESS-TOKEN, X-TIMEZONE, X-Requested-With",
-			"Access-Control-Expose-Headers": "X-API-KEY, X-ACCESS-TOKEN, X-TIMEZONE, Content-Disposition, Content-Filename",
-			"Access-Control-Max-Age":        "86400",
-		}
-
-		for k, v := range defaultHeaders {
-			if _, ok := serverInst.headers[k]; !ok {
-				c.Writer.Header().Set(k, v)
-			}
-		}
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-
-		c.Next()
-	}
+func recoverPanic(c *gin.Context, recovered any) {
+	logger.Error("panic recovered on path: %s: %v", c.Request.URL.Path, recovered)
+	RenderError(c, errInternal)
 }
 
 // Add response header with API version
-func apiVersion() gin.HandlerFunc {
+func (s *Server) apiVersion() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Header("X-API-VERSION", "s.version")
+		c.Header("X-API-VERSION", s.version)
 	}
 }
 
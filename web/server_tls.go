@@ -0,0 +1,114 @@
+package web
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// region TLS configuration fluent API ---------------------------------------------------------------------------------
+
+// tlsSettings holds the TLS and mutual TLS configuration of the server
+type tlsSettings struct {
+	certFile     string             // Server certificate file (PEM)
+	keyFile      string             // Server private key file (PEM)
+	config       *tls.Config        // In-memory TLS configuration (overrides defaults)
+	clientCAFile string             // Client certificate authorities file (PEM)
+	clientCAs    *x509.CertPool     // Client certificate authorities pool
+	clientAuth   tls.ClientAuthType // Client certificate verification policy
+}
+
+// WithTLS serves HTTPS using the provided certificate and private key files (PEM format)
+func (s *Server) WithTLS(certFile, keyFile string) *Server {
+	s.tls.certFile = certFile
+	s.tls.keyFile = keyFile
+	return s
+}
+
+// WithTLSConfig serves HTTPS using the provided in-memory TLS configuration
+// The configuration must include the server certificates (Certificates or GetCertificate)
+func (s *Server) WithTLSConfig(config *tls.Config) *Server {
+	s.tls.config = config
+	return s
+}
+
+// WithClientCA enables client certificate verification against the CA certificates in the provided file (PEM format)
+// When required is true, connections without a valid client certificate are rejected during the TLS handshake,
+// otherwise the client certificate is verified only if provided
+func (s *Server) WithClientCA(caFile string, required bool) *Server {
+	s.tls.clientCAFile = caFile
+	s.tls.clientAuth = clientAuthType(required)
+	return s
+}
+
+// WithClientCAPool enables client certificate verification against the provided CA pool
+// When required is true, connections without a valid client certificate are rejected during the TLS handshake,
+// otherwise the client certificate is verified only if provided
+func (s *Server) WithClientCAPool(pool *x509.CertPool, required bool) *Server {
+	s.tls.clientCAs = pool
+	s.tls.clientAuth = clientAuthType(required)
+	return s
+}
+
+// endregion
+
+// region TLS configuration helpers ------------------------------------------------------------------------------------
+
+// enabled returns true if the server should listen on TLS
+func (t *tlsSettings) enabled() bool {
+	return t.config != nil || (len(t.certFile) > 0 && len(t.keyFile) > 0)
+}
+
+// build the TLS configuration for the HTTP server (nil if TLS is not configured)
+func (t *tlsSettings) build() (*tls.Config, error) {
+
+	if !t.enabled() {
+		if len(t.clientCAFile) > 0 || t.clientCAs != nil {
+			return nil, fmt.Errorf("client certificate verification requires TLS, use WithTLS or WithTLSConfig")
+		}
+		return nil, nil
+	}
+
+	var config *tls.Config
+	if t.config != nil {
+		config = t.config.Clone()
+	} else {
+		config = &tls.Config{}
+	}
+	if config.MinVersion == 0 {
+		config.MinVersion = tls.VersionTLS12
+	}
+
+	// Load client certificate authorities
+	pool := t.clientCAs
+	if len(t.clientCAFile) > 0 {
+		pem, err := os.ReadFile(t.clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading client CA file: %s", err.Error())
+		}
+		if pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificates found in client CA file: %s", t.clientCAFile)
+		}
+	}
+
+	if pool != nil {
+		config.ClientCAs = pool
+		config.ClientAuth = t.clientAuth
+	}
+	return config, nil
+}
+
+// Get client authentication type by the required flag
+func clientAuthType(required bool) tls.ClientAuthType {
+	if required {
+		return tls.RequireAndVerifyClientCert
+	} else {
+		return tls.VerifyClientCertIfGiven
+	}
+}
+
+// endregion
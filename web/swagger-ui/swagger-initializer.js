@@ -0,0 +1,7 @@
+window.onload = () => {
+    const element = document.getElementById("swagger-ui");
+    window.ui = SwaggerUIBundle({
+        url: element.dataset.url,
+        dom_id: "#swagger-ui",
+    });
+};
@@ -1,8 +1,11 @@
 package web
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-yaaf/yaaf-common/logger"
@@ -14,73 +17,234 @@ import (
 
 // WSClient represent single web socket client handler
 type WSClient struct {
-	id             string          // Web socket client unique ID
-	conn           *websocket.Conn // Pointer to the underlying web socket connection
-	decoder        IMessageDecoder // Message decoder (if empty use default JSON decoder)
-	handlers       map[int]WSEntry // Map of Web Socket entries
-	onDisconnected DisconnectedCb  // Client disconnect callback
-	send           chan []byte
+	id             string           // Web socket client unique ID
+	conn           *websocket.Conn  // Pointer to the underlying web socket connection
+	decoder        IMessageDecoder  // Message decoder (if empty use default JSON decoder)
+	handlers       map[int]WSEntry  // Map of Web Socket entries
+	fallback       WSMessageHandler // Handler of messages without entry for their op-code
+	dispatchMode   WSDispatchMode   // Concurrent or sequential dispatch
+	onDisconnected DisconnectedCb   // Client disconnect callback
+	keepAlive      WSKeepAlive      // Ping/pong keepalive configuration
+	latency        atomic.Int64     // Last ping round trip latency [nanoseconds]
+	stop           chan struct{}    // Closed when the read loop ends
+	send           chan []byte      // Send queue drained by the write pump
+	sendPolicy     WSQueuePolicy    // Policy when the send queue is full
+	sendMu         sync.Mutex       // Serializes the producers of the send queue
+	sent           atomic.Uint64    // Number of messages written to the connection
+	dropped        atomic.Uint64    // Number of messages dropped by the send queue policy
+	closing        chan struct{}    // Closed by Close to flush the send queue
+	writerDone     chan struct{}    // Closed when the write pump ends
+	closeOnce      sync.Once
+	closed         atomic.Bool
+	disconnectOnce sync.Once
 }
 
+// WSClientStats is the send queue metrics of a web socket client
+type WSClientStats struct {
+	QueueDepth int    // Number of messages waiting in the send queue
+	QueueSize  int    // The send queue capacity
+	Sent       uint64 // Number of messages written to the connection
+	Dropped    uint64 // Number of messages dropped by the send queue policy
+}
+
+const (
+	wsDefaultSendQueueSize = 256
+	wsWriteTimeout         = 60 * time.Second
+)
+
 // WSClientConfig is the configuration for a web socket client
 type WSClientConfig struct {
-	Id           string
-	WsConn       *websocket.Conn
-	Handlers     map[int]WSEntry
-	onDisconnect DisconnectedCb
+	Id           string           // Web socket client unique ID
+	WsConn       *websocket.Conn  // The underlying web socket connection
+	Decoder      IMessageDecoder  // Message decoder (if empty use default JSON decoder)
+	Handlers     map[int]WSEntry  // Map of message op-code to Web Socket entry
+	OnDisconnect DisconnectedCb   // Client disconnect callback
+	KeepAlive    WSKeepAlive      // Ping/pong keepalive (disabled if not provided)
+	SendQueue    WSSendQueue      // Send queue size and slow consumer policy
+	Fallback     WSMessageHandler // Handler of messages without entry for their op-code (ignored if not provided)
+	Dispatch     WSDispatchMode   // Concurrent (default) or sequential dispatch
 }
 
 // NewWsClient creates a new web socket client
 func NewWsClient(clientId string, conn *websocket.Conn, onDisconnect DisconnectedCb) IWSClient {
+	return NewWsClientWithConfig(WSClientConfig{Id: clientId, WsConn: conn, OnDisconnect: onDisconnect})
+}
+
+// NewWsClientWithConfig creates a new web socket client with message decoder and handlers
+func NewWsClientWithConfig(cfg WSClientConfig) IWSClient {
 
 	ws := &WSClient{
-		id:             clientId,
-		conn:           conn,
-		onDisconnected: onDisconnect,
+		id:             cfg.Id,
+		conn:           cfg.WsConn,
+		decoder:        cfg.Decoder,
+		handlers:       cfg.Handlers,
+		fallback:       cfg.Fallback,
+		dispatchMode:   cfg.Dispatch,
+		onDisconnected: cfg.OnDisconnect,
+		keepAlive:      cfg.KeepAlive,
+		stop:           make(chan struct{}),
+		send:           make(chan []byte, cfg.SendQueue.size()),
+		sendPolicy:     cfg.SendQueue.Policy,
+		closing:        make(chan struct{}),
+		writerDone:     make(chan struct{}),
 	}
 
-	ws.decoder = NewJsonDecoder()
+	if ws.decoder == nil {
+		ws.decoder = NewJsonDecoder()
+	}
 
 	if ws.conn != nil {
+		ws.extendReadDeadline()
+		ws.conn.SetPongHandler(ws.onPong)
 		go ws.run()
+		go ws.writePump()
+		if ws.keepAlive.PingInterval > 0 {
+			go ws.ping()
+		}
 	}
 	return ws
 }
 
+// Map the Web Socket entries by their message op-code
+func wsHandlers(entries []WSEntry) map[int]WSEntry {
+	handlers := make(map[int]WSEntry, len(entries))
+	for _, entry := range entries {
+		handlers[entry.OpCode] = entry
+	}
+	return handlers
+}
+
+// Get the message decoder of the Web Socket entries: the JSON decoder (default) creates the messages by the factories
+// of the entries, so endpoints and clients using the same op-code for different messages don't override each other
+// Custom decoders are used as is
+func wsDecoder(decoder IMessageDecoder, entries []WSEntry) IMessageDecoder {
+	factories := make(map[int]MessageFactoryFunc)
+	switch d := decoder.(type) {
+	case nil:
+	case *JsonDecoder:
+		for opCode, f := range d.factories {
+			factories[opCode] = f
+		}
+	default:
+		return decoder
+	}
+	for _, entry := range entries {
+		if entry.Message != nil {
+			factories[entry.OpCode] = entry.Message
+		}
+	}
+	return NewJsonDecoderWithFactories(factories)
+}
+
 // ID returns the client ID
 func (c *WSClient) ID() string {
 	return c.id
 }
 
-// Send typed message
+// Send typed message (encoded by the client message decoder)
 func (c *WSClient) Send(msg IWSMessage) error {
-	if buffer, err := json.Marshal(msg); err == nil {
+	if buffer, err := c.decoder.Encode(msg); err == nil {
 		return c.SendRaw(buffer)
 	} else {
 		return fmt.Errorf("websocket client [%s]: message marshal failed: %v", c.id, err)
 	}
 }
 
-// SendRaw send raw message
+// SendRaw queues raw message to be written by the client write pump
+// When the send queue is full, the message is handled by the slow consumer policy (see WSSendQueue)
 func (c *WSClient) SendRaw(buffer []byte) error {
-	// Set write deadline to 60 seconds
-	deadLine := time.Now().Add(time.Second * time.Duration(60))
-	_ = c.conn.SetWriteDeadline(deadLine)
+	if c.conn == nil || c.closed.Load() {
+		return fmt.Errorf("websocket client [%s]: send failed: connection closed", c.id)
+	}
 
-	if err := c.conn.WriteMessage(websocket.TextMessage, buffer); err != nil {
-		if c.onDisconnected != nil {
-			c.onDisconnected(c)
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	select {
+	case c.send <- buffer:
+		return nil
+	default:
+	}
+
+	switch c.sendPolicy {
+	case WSDropOldest:
+		select {
+		case <-c.send:
+			c.dropped.Add(1)
+		default:
 		}
-		return fmt.Errorf("websocket client [%s]: send failed: %v", c.id, err)
-	} else {
+		select {
+		case c.send <- buffer:
+			return nil
+		default:
+		}
+	case WSDisconnect:
+		// Closing the connection ends the read loop, which reports the disconnect
+		c.dropped.Add(1)
+		_ = c.conn.Close()
+		return fmt.Errorf("websocket client [%s]: send queue is full, slow client disconnected", c.id)
+	}
+	c.dropped.Add(1)
+	return fmt.Errorf("websocket client [%s]: send queue is full, message dropped", c.id)
+}
+
+// Queue raw message, waiting for room in the send queue instead of applying the slow consumer policy
+// Fails when the connection is closed before the message is queued or the context is done
+func (c *WSClient) sendWait(ctx context.Context, buffer []byte) error {
+	if c.conn == nil || c.closed.Load() {
+		return fmt.Errorf("websocket client [%s]: send failed: connection closed", c.id)
+	}
+
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	select {
+	case c.send <- buffer:
 		return nil
+	case <-c.stop:
+	case <-c.writerDone:
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+	return fmt.Errorf("websocket client [%s]: send failed: connection closed", c.id)
 }
 
-// Close closes the connection
+// Close flushes the send queue (up to 1 second), sends a close frame to the peer and closes the connection
 func (c *WSClient) Close() error {
-	close(c.send)
-	return c.conn.Close()
+	return c.closeContext(context.Background())
+}
+
+// Close the client, the send queue flush and the close frame are limited by the context deadline as well
+func (c *WSClient) closeContext(ctx context.Context) (err error) {
+	c.closeOnce.Do(func() {
+		c.closed.Store(true)
+		if c.conn == nil {
+			return
+		}
+		close(c.closing)
+		select {
+		case <-c.writerDone:
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+		}
+		deadLine := time.Now().Add(time.Second)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadLine) {
+			deadLine = d
+		}
+		_ = c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), deadLine)
+		err = c.conn.Close()
+	})
+	return err
+}
+
+// Stats returns the send queue metrics
+func (c *WSClient) Stats() WSClientStats {
+	return WSClientStats{QueueDepth: len(c.send), QueueSize: cap(c.send), Sent: c.sent.Load(), Dropped: c.dropped.Load()}
+}
+
+// Latency returns the round trip latency of the last ping (0 before the first pong)
+func (c *WSClient) Latency() time.Duration {
+	return time.Duration(c.latency.Load())
 }
 
 // RemoteAddress returns the remote address of the client
@@ -91,26 +255,159 @@ func (c *WSClient) RemoteAddress() (ra string) {
 	return
 }
 
+// Notify the disconnect callback (once)
+func (c *WSClient) disconnected() {
+	c.disconnectOnce.Do(func() {
+		if c.onDisconnected != nil {
+			c.onDisconnected(c)
+		}
+	})
+}
+
+// Read messages until the connection is closed locally, closed by the peer or the peer is gone (read deadline)
 func (c *WSClient) run() {
 
 	defer utils.RecoverAll(func(err interface{}) {
-		logger.Error("WSClient::run error: %s", err)
+		if err != nil {
+			logger.Error("WSClient::run error: %s", err)
+		}
 	})
+	defer close(c.stop)
 
 	for {
 		if _, rawMessage, err := c.conn.ReadMessage(); err != nil {
-			continue
+			// Connection closed by the peer or the peer is gone, notify and release the connection
+			if !c.closed.Load() {
+				c.disconnected()
+				_ = c.Close()
+			}
+			return
 		} else {
+			c.extendReadDeadline()
 			if msg, fe := c.decoder.Decode(rawMessage); fe != nil {
 				logger.Error("error decoding received message from: [%s]: error: %s message dump: %s", c.id, fe.Error(), string(rawMessage))
 			} else {
-				if len(c.handlers) > 0 {
-					if mh, ok := c.handlers[msg.MessageCode()]; ok {
-						go func() { _ = mh.Handler(msg, c) }()
+				c.dispatch(msg)
+			}
+		}
+	}
+}
+
+// Dispatch the message to the handler of its op-code (or the fallback handler)
+func (c *WSClient) dispatch(msg IWSMessage) {
+	handler := c.fallback
+	if entry, ok := c.handlers[msg.MessageCode()]; ok && entry.Handler != nil {
+		handler = entry.Handler
+	}
+	if handler == nil {
+		logger.Debug("websocket client [%s]: no handler for message op-code: %d", c.id, msg.MessageCode())
+		return
+	}
+
+	if c.dispatchMode == WSDispatchSequential {
+		c.handle(handler, msg)
+	} else {
+		go c.handle(handler, msg)
+	}
+}
+
+// Invoke the handler and send the handler error back to the peer (errors of error messages are not sent back)
+func (c *WSClient) handle(handler WSMessageHandler, msg IWSMessage) {
+	if err := c.invoke(handler, msg); err != nil && msg.MessageCode() != WsErrorOpCode {
+		if er := c.Send(NewWsErrorMessage(msg, err)); er != nil {
+			logger.Warn("websocket client [%s]: failed to send error message: %s", c.id, er.Error())
+		}
+	}
+}
+
+// Invoke the handler, a handler panic is recovered and returned as error
+func (c *WSClient) invoke(handler WSMessageHandler, msg IWSMessage) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("websocket client [%s]: message handler panic, op-code: %d: %v", c.id, msg.MessageCode(), r)
+			err = fmt.Errorf("internal error handling message op-code: %d", msg.MessageCode())
+		}
+	}()
+	return handler(msg, c)
+}
+
+// Write the queued messages to the connection (the only writer of data messages), until the connection is closed
+func (c *WSClient) writePump() {
+	defer close(c.writerDone)
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case buffer := <-c.send:
+			if err := c.write(buffer, time.Now().Add(wsWriteTimeout)); err != nil {
+				// Closing the connection ends the read loop, which reports the disconnect
+				_ = c.conn.Close()
+				return
+			}
+		case <-c.closing:
+			// Flush the queued messages
+			deadLine := time.Now().Add(time.Second)
+			for {
+				select {
+				case buffer := <-c.send:
+					if err := c.write(buffer, deadLine); err != nil {
 						return
 					}
+				default:
+					return
 				}
 			}
 		}
 	}
 }
+
+// Write data message to the connection
+func (c *WSClient) write(buffer []byte, deadLine time.Time) error {
+	_ = c.conn.SetWriteDeadline(deadLine)
+	if err := c.conn.WriteMessage(websocket.TextMessage, buffer); err != nil {
+		return err
+	}
+	c.sent.Add(1)
+	return nil
+}
+
+// Send ping control frames (carrying the send time) until the read loop ends
+func (c *WSClient) ping() {
+	ticker := time.NewTicker(c.keepAlive.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			data := []byte(strconv.FormatInt(time.Now().UnixNano(), 10))
+			if err := c.conn.WriteControl(websocket.PingMessage, data, time.Now().Add(c.keepAlive.PingInterval)); err != nil {
+				// Closing the connection ends the read loop, which reports the disconnect
+				_ = c.conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// Pong handler: measure the round trip latency and extend the read deadline
+func (c *WSClient) onPong(data string) error {
+	c.extendReadDeadline()
+	if sent, err := strconv.ParseInt(data, 10, 64); err == nil {
+		latency := time.Since(time.Unix(0, sent))
+		c.latency.Store(int64(latency))
+		if c.keepAlive.OnPong != nil {
+			c.keepAlive.OnPong(c.id, data, latency.Milliseconds())
+		}
+	}
+	return nil
+}
+
+// Extend the read deadline by the pong timeout (no deadline when the keepalive is disabled)
+func (c *WSClient) extendReadDeadline() {
+	if timeout := c.keepAlive.timeout(); timeout > 0 {
+		_ = c.conn.SetReadDeadline(time.Now().Add(timeout))
+	}
+}
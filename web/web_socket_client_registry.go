@@ -1,6 +1,7 @@
 package web
 
 import (
+	"context"
 	"sync"
 )
 
@@ -12,27 +13,35 @@ type DefaultClientRegistry struct {
 	register    chan IWSClient
 	unregister  chan IWSClient
 	broadcast   chan []byte
+	done        chan struct{}
+	stopOnce    sync.Once
 }
 
 // NewClientRegistry factory method
 func NewClientRegistry(group string) IWSClientRegistry {
 	return &DefaultClientRegistry{
 		Connections: make(map[string]IWSClient),
+		register:    make(chan IWSClient),
+		unregister:  make(chan IWSClient),
+		broadcast:   make(chan []byte),
+		done:        make(chan struct{}),
 	}
 }
 
-// Start Initialize registry
+// Start Initialize registry, this call blocks until the registry is stopped
 func (r *DefaultClientRegistry) Start() {
 	for {
 		select {
+		case <-r.done:
+			return
 		case c := <-r.register:
 			r.Lock()
 			r.Connections[c.ID()] = c
 			r.Unlock()
 		case c := <-r.unregister:
-			r.Lock()
-			r.removeClient(c.ID())
-			r.Unlock()
+			if conn := r.removeClient(c.ID()); conn != nil {
+				go func() { _ = conn.Close() }()
+			}
 		case msg := <-r.broadcast:
 			r.Lock()
 			for _, c := range r.Connections {
@@ -43,11 +52,57 @@ func (r *DefaultClientRegistry) Start() {
 	}
 }
 
-func (r *DefaultClientRegistry) removeClient(id string) {
+// Stop terminates the registry loop and closes all the connected clients in parallel (sending close frame)
+// Returns the context error if the clients were not closed before the context is done
+func (r *DefaultClientRegistry) Stop(ctx context.Context) error {
+	var clients []IWSClient
+	r.stopOnce.Do(func() {
+		close(r.done)
+
+		r.Lock()
+		for id, c := range r.Connections {
+			delete(r.Connections, id)
+			clients = append(clients, c)
+		}
+		r.Unlock()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var wg sync.WaitGroup
+		for _, c := range clients {
+			wg.Add(1)
+			go func(c IWSClient) {
+				defer wg.Done()
+				if wc, ok := c.(*WSClient); ok {
+					_ = wc.closeContext(ctx)
+				} else {
+					_ = c.Close()
+				}
+			}(c)
+		}
+		wg.Wait()
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Remove the client from the registry and return it (nil if not registered), the client is closed by the caller
+// without holding the registry lock (closing may wait for the pending messages and the close frame)
+func (r *DefaultClientRegistry) removeClient(id string) IWSClient {
+	r.Lock()
+	defer r.Unlock()
 	if conn, ok := r.Connections[id]; ok {
 		delete(r.Connections, id)
-		_ = conn.Close()
+		return conn
 	}
+	return nil
 }
 
 // RegisterClient Register new connected client
@@ -64,12 +119,9 @@ func (r *DefaultClientRegistry) RegisterClient(wsc IWSClient) {
 
 // UnregisterClient Unregister disconnected client
 func (r *DefaultClientRegistry) UnregisterClient(wsc IWSClient) {
-	r.Lock()
-	if conn, ok := r.Connections[wsc.ID()]; ok {
-		delete(r.Connections, wsc.ID())
+	if conn := r.removeClient(wsc.ID()); conn != nil {
 		_ = conn.Close()
 	}
-	r.Unlock()
 }
 
 // ConnectedClients Get number of current connected clients
@@ -0,0 +1,87 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// region Web Socket client dialer -------------------------------------------------------------------------------------
+
+const wsDefaultBufferSize = 8 * 1024
+
+// DialWsClient connects to a web socket server and returns a client speaking the same message protocol as the server:
+// outgoing messages are encoded and incoming messages are decoded by the message decoder (see WSConnectParams.Decoder)
+// and dispatched to the handler of their op-code
+func DialWsClient(ctx context.Context, params WSConnectParams, entries ...WSEntry) (IWSClient, error) {
+
+	wsUrl, err := params.url()
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &websocket.Dialer{
+		Proxy:             http.ProxyFromEnvironment,
+		HandshakeTimeout:  45 * time.Second,
+		ReadBufferSize:    bufferSize(params.ReadBufferSize),
+		WriteBufferSize:   bufferSize(params.WriteBufferSize),
+		EnableCompression: params.CompressionEnabled,
+		TLSClientConfig:   params.TLSConfig,
+	}
+
+	conn, res, err := dialer.DialContext(ctx, wsUrl, params.Header)
+	if err != nil {
+		if res != nil {
+			return nil, fmt.Errorf("websocket dial %s failed: %s: %v", wsUrl, res.Status, err)
+		}
+		return nil, fmt.Errorf("websocket dial %s failed: %v", wsUrl, err)
+	}
+
+	return NewWsClientWithConfig(WSClientConfig{
+		Id:           uuid.New().String(),
+		WsConn:       conn,
+		Decoder:      wsDecoder(params.Decoder, entries),
+		Handlers:     wsHandlers(entries),
+		OnDisconnect: params.OnDisconnect,
+		KeepAlive:    params.KeepAlive,
+		SendQueue:    params.SendQueue,
+		Fallback:     params.Fallback,
+		Dispatch:     params.Dispatch,
+	}), nil
+}
+
+// Get the connection URL: the full URL or ws://{host}{path}
+func (p *WSConnectParams) url() (string, error) {
+	wsUrl := p.Url
+	if len(wsUrl) == 0 {
+		if len(p.Host) == 0 {
+			return "", fmt.Errorf("websocket URL or host is required")
+		}
+		wsUrl = "ws://" + p.Host + "/" + strings.TrimPrefix(p.Path, "/")
+	}
+
+	u, err := url.Parse(wsUrl)
+	if err != nil {
+		return "", fmt.Errorf("invalid websocket URL %s: %v", wsUrl, err)
+	}
+	if u.Scheme != "ws" && u.Scheme != "wss" {
+		return "", fmt.Errorf("invalid websocket URL scheme: %s", u.Scheme)
+	}
+	return wsUrl, nil
+}
+
+// Get the buffer size or the default 8K
+func bufferSize(size int) int {
+	if size > 0 {
+		return size
+	}
+	return wsDefaultBufferSize
+}
+
+// endregion
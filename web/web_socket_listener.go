@@ -1,6 +1,7 @@
 package web
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
@@ -21,27 +22,47 @@ var upgrader = websocket.Upgrader{
 
 // WSListener is a wrapper for web socket listener
 type WSListener struct {
-	registry IWSClientRegistry
-	decoder  IMessageDecoder
-	handlers map[int]WSEntry
+	registry  IWSClientRegistry
+	decoder   IMessageDecoder
+	handlers  map[int]WSEntry
+	fallback  WSMessageHandler
+	dispatch  WSDispatchMode
+	keepAlive WSKeepAlive
+	sendQueue WSSendQueue
 }
 
-// NewListener factory method
+// NewListener factory method, the messages of the connected clients are dispatched to the endpoint entries by op-code
+// (see IWSFallbackEndpoint and IWSDispatchEndpoint for the optional endpoint dispatch configuration)
 func NewListener(registry IWSClientRegistry, cfg IWSEndpointConfig) (wsh *WSListener) {
 	wsh = &WSListener{
 		registry: registry,
-		handlers: make(map[int]WSEntry, len(cfg.WSEntries())),
+		handlers: wsHandlers(cfg.WSEntries()),
 	}
 
 	// Set message decoder
-	wsh.decoder = NewJsonDecoder()
+	wsh.decoder = wsDecoder(nil, cfg.WSEntries())
 
-	for k, handlerEntry := range cfg.WSEntries() {
-		wsh.handlers[k] = handlerEntry
+	if fe, ok := cfg.(IWSFallbackEndpoint); ok {
+		wsh.fallback = fe.Fallback
+	}
+	if de, ok := cfg.(IWSDispatchEndpoint); ok {
+		wsh.dispatch = de.DispatchMode()
 	}
 	return
 }
 
+// WithKeepAlive sets the ping/pong keepalive of the connected clients
+func (h *WSListener) WithKeepAlive(keepAlive WSKeepAlive) *WSListener {
+	h.keepAlive = keepAlive
+	return h
+}
+
+// WithSendQueue sets the send queue size and slow consumer policy of the connected clients
+func (h *WSListener) WithSendQueue(sendQueue WSSendQueue) *WSListener {
+	h.sendQueue = sendQueue
+	return h
+}
+
 // ListenForWSConnections Listen for web socket connections
 func (h *WSListener) ListenForWSConnections(w http.ResponseWriter, r *http.Request) {
 
@@ -87,13 +108,29 @@ func (h *WSListener) ListenForWSConnections(w http.ResponseWriter, r *http.Reque
 	//conn.EnableWriteCompression(true)
 	conn.EnableWriteCompression(false)
 
-	tcpConn := conn.NetConn().(*net.TCPConn)
-	_ = tcpConn.SetLinger(0)
-	_ = tcpConn.SetNoDelay(true)
-	_ = tcpConn.SetWriteBuffer(1048576)
-	_ = tcpConn.SetReadBuffer(1048576)
+	// Tune the underlying TCP connection (unwrap TLS connection)
+	netConn := conn.NetConn()
+	if tlsConn, ok := netConn.(*tls.Conn); ok {
+		netConn = tlsConn.NetConn()
+	}
+	if tcpConn, ok := netConn.(*net.TCPConn); ok {
+		_ = tcpConn.SetLinger(0)
+		_ = tcpConn.SetNoDelay(true)
+		_ = tcpConn.SetWriteBuffer(1048576)
+		_ = tcpConn.SetReadBuffer(1048576)
+	}
 
-	wsClient := NewWsClient(clientId, conn, h.onDisconnected)
+	wsClient := NewWsClientWithConfig(WSClientConfig{
+		Id:           clientId,
+		WsConn:       conn,
+		Decoder:      h.decoder,
+		Handlers:     h.handlers,
+		Fallback:     h.fallback,
+		Dispatch:     h.dispatch,
+		OnDisconnect: h.onDisconnected,
+		KeepAlive:    h.keepAlive,
+		SendQueue:    h.sendQueue,
+	})
 	h.registry.RegisterClient(wsClient)
 	return
 }
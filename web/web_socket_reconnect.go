@@ -0,0 +1,327 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// region Reconnecting client configuration ----------------------------------------------------------------------------
+
+// WSConnectionState is the connection state of a reconnecting web socket client
+type WSConnectionState int
+
+const (
+	WSConnecting   WSConnectionState = iota // Dialing the server
+	WSConnected                             // Connected, messages are sent directly
+	WSDisconnected                          // Connection lost or dial failed, waiting to reconnect
+	WSClosed                                // Closed by Close or by reaching the max attempts, no more reconnects
+)
+
+// String returns the state name
+func (s WSConnectionState) String() string {
+	switch s {
+	case WSConnecting:
+		return "connecting"
+	case WSConnected:
+		return "connected"
+	case WSDisconnected:
+		return "disconnected"
+	case WSClosed:
+		return "closed"
+	}
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
+
+// WSStateChangedCb called when the connection state changes (err is the dial or disconnect reason, if any)
+type WSStateChangedCb func(client IWSClient, state WSConnectionState, err error)
+
+// WSReconnectConfig is the reconnect policy and outbound queue configuration of a reconnecting client
+type WSReconnectConfig struct {
+	InitialDelay   time.Duration       // Delay before the first reconnect attempt (default: 500ms)
+	MaxDelay       time.Duration       // Max delay between attempts (default: 30s)
+	Multiplier     float64             // Delay multiplier of every failed attempt (default: 2)
+	Jitter         float64             // Random delay deviation as fraction of the delay, up to 1 (0 for 0.2, WSNoJitter for none)
+	MaxAttempts    int                 // Consecutive failed attempts before closing the client (0 for never)
+	QueueSize      int                 // Max messages buffered while disconnected (default: 1000)
+	QueuePolicy    WSQueuePolicy       // Policy when the queue is full: drop oldest (default) or drop newest
+	Subscriptions  func() []IWSMessage // Messages replayed on every connection, before the buffered messages
+	OnStateChanged WSStateChangedCb    // Connection state observer
+}
+
+// WSNoJitter disables the random deviation of the reconnect delay (see WSReconnectConfig.Jitter)
+const WSNoJitter = -1.0
+
+// Get the delay before the reconnect attempt (1 based), exponential with jitter
+func (cfg *WSReconnectConfig) delay(attempt int) time.Duration {
+	initial, maxDelay, multiplier, jitter := cfg.InitialDelay, cfg.MaxDelay, cfg.Multiplier, cfg.Jitter
+	if initial <= 0 {
+		initial = 500 * time.Millisecond
+	}
+	if maxDelay <= 0 {
+		maxDelay = 30 * time.Second
+	}
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	if jitter < 0 {
+		jitter = 0
+	} else if jitter == 0 || jitter > 1 {
+		jitter = 0.2
+	}
+
+	d := math.Min(float64(initial)*math.Pow(multiplier, float64(attempt-1)), float64(maxDelay))
+	d *= 1 + jitter*(2*rand.Float64()-1)
+	return time.Duration(d)
+}
+
+// Get the outbound queue size
+func (cfg *WSReconnectConfig) queueSize() int {
+	if cfg.QueueSize > 0 {
+		return cfg.QueueSize
+	}
+	return 1000
+}
+
+// endregion
+
+// region Reconnecting client ------------------------------------------------------------------------------------------
+
+// WSReconnectingClient is an outbound web socket client that reconnects automatically when the connection drops
+// Messages sent while disconnected are buffered and sent after the reconnection
+type WSReconnectingClient struct {
+	id      string
+	params  WSConnectParams
+	cfg     WSReconnectConfig
+	entries []WSEntry
+	decoder IMessageDecoder
+	ctx     context.Context
+	cancel  context.CancelFunc
+	client  IWSClient // The current connection client (nil while disconnected)
+	queue   [][]byte  // Messages buffered while disconnected
+	state   WSConnectionState
+	mu      sync.Mutex
+}
+
+// DialReconnectingWsClient creates a web socket client connecting in the background and reconnecting with
+// exponential backoff when the connection drops, until the context is done or the client is closed
+// The handlers of the entries get the reconnecting client, so replies sent while disconnected are buffered as well
+func DialReconnectingWsClient(ctx context.Context, params WSConnectParams, cfg WSReconnectConfig, entries ...WSEntry) (IWSClient, error) {
+	if _, err := params.url(); err != nil {
+		return nil, err
+	}
+
+	r := &WSReconnectingClient{id: uuid.New().String(), params: params, cfg: cfg, decoder: params.Decoder, state: WSDisconnected}
+	if r.decoder == nil {
+		r.decoder = NewJsonDecoder()
+	}
+	r.ctx, r.cancel = context.WithCancel(ctx)
+
+	for _, entry := range entries {
+		if handler := entry.Handler; handler != nil {
+			entry.Handler = func(m IWSMessage, _ IWSClient) error { return handler(m, r) }
+		}
+		r.entries = append(r.entries, entry)
+	}
+	if fallback := params.Fallback; fallback != nil {
+		r.params.Fallback = func(m IWSMessage, _ IWSClient) error { return fallback(m, r) }
+	}
+
+	go r.run()
+	return r, nil
+}
+
+// ID returns the client ID (unchanged by reconnects)
+func (r *WSReconnectingClient) ID() string {
+	return r.id
+}
+
+// State returns the current connection state
+func (r *WSReconnectingClient) State() WSConnectionState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state
+}
+
+// Send typed message (encoded by the message decoder), the message is buffered while disconnected
+func (r *WSReconnectingClient) Send(msg IWSMessage) error {
+	buffer, err := r.decoder.Encode(msg)
+	if err != nil {
+		return fmt.Errorf("websocket client [%s]: message marshal failed: %v", r.id, err)
+	}
+	return r.SendRaw(buffer)
+}
+
+// SendRaw send raw message, the message is buffered while disconnected
+func (r *WSReconnectingClient) SendRaw(buffer []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.state == WSClosed {
+		return fmt.Errorf("websocket client [%s]: client is closed", r.id)
+	}
+	if r.client != nil {
+		err := r.client.SendRaw(buffer)
+		if err == nil {
+			return nil
+		}
+		// Send queue of the open connection is full, otherwise keep the message for the next connection
+		if wc, ok := r.client.(*WSClient); ok && !wc.closed.Load() {
+			return err
+		}
+	}
+	return r.enqueue(buffer)
+}
+
+// Close stops reconnecting and closes the current connection (buffered messages are dropped)
+func (r *WSReconnectingClient) Close() (err error) {
+	r.cancel()
+
+	r.mu.Lock()
+	if r.client != nil {
+		err = r.client.Close()
+		r.client = nil
+	}
+	r.queue = nil
+	r.mu.Unlock()
+
+	r.setState(WSClosed, nil)
+	return err
+}
+
+// Add message to the outbound queue (must be called under lock)
+func (r *WSReconnectingClient) enqueue(buffer []byte) error {
+	if len(r.queue) >= r.cfg.queueSize() {
+		if r.cfg.QueuePolicy != WSDropOldest {
+			return fmt.Errorf("websocket client [%s]: send queue is full, message dropped", r.id)
+		}
+		r.queue = r.queue[1:]
+	}
+	r.queue = append(r.queue, buffer)
+	return nil
+}
+
+// Connect and reconnect until the context is done or the max attempts is reached, then close the client
+func (r *WSReconnectingClient) run() {
+	defer func() { _ = r.Close() }()
+
+	attempt := 0
+	for {
+		r.setState(WSConnecting, nil)
+
+		lost := make(chan struct{})
+		var lostOnce sync.Once
+		params := r.params
+		params.OnDisconnect = func(IWSClient) { lostOnce.Do(func() { close(lost) }) }
+
+		// The connection send queue can hold the whole outbound queue
+		if params.SendQueue.size() < r.cfg.queueSize() {
+			params.SendQueue.Size = r.cfg.queueSize()
+		}
+
+		client, err := DialWsClient(r.ctx, params, r.entries...)
+		if err == nil {
+			if err = r.connected(client); err == nil {
+				attempt = 0
+				r.setState(WSConnected, nil)
+
+				select {
+				case <-r.ctx.Done():
+					return
+				case <-lost:
+				}
+
+				r.mu.Lock()
+				r.client = nil
+				r.mu.Unlock()
+				_ = client.Close()
+				if r.params.OnDisconnect != nil {
+					r.params.OnDisconnect(r)
+				}
+				err = fmt.Errorf("connection lost")
+			}
+		}
+
+		if r.ctx.Err() != nil {
+			return
+		}
+		attempt++
+		if r.cfg.MaxAttempts > 0 && attempt > r.cfg.MaxAttempts {
+			r.setState(WSClosed, err)
+			return
+		}
+		r.setState(WSDisconnected, err)
+
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-time.After(r.cfg.delay(attempt)):
+		}
+	}
+}
+
+// Replay the subscriptions and flush the outbound queue to the new connection
+// Messages are not dropped by the connection send queue, messages not accepted remain in the outbound queue
+func (r *WSReconnectingClient) connected(client IWSClient) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ctx.Err() != nil {
+		_ = client.Close()
+		return r.ctx.Err()
+	}
+
+	if r.cfg.Subscriptions != nil {
+		for _, msg := range r.cfg.Subscriptions() {
+			buffer, err := r.decoder.Encode(msg)
+			if err == nil {
+				err = r.flush(client, buffer)
+			}
+			if err != nil {
+				_ = client.Close()
+				return err
+			}
+		}
+	}
+
+	for len(r.queue) > 0 {
+		if err := r.flush(client, r.queue[0]); err != nil {
+			_ = client.Close()
+			return err
+		}
+		r.queue = r.queue[1:]
+	}
+
+	r.client = client
+	return nil
+}
+
+// Send message to the new connection, waiting for room in its send queue (must be called under lock)
+func (r *WSReconnectingClient) flush(client IWSClient, buffer []byte) error {
+	if wc, ok := client.(*WSClient); ok {
+		return wc.sendWait(r.ctx, buffer)
+	}
+	return client.SendRaw(buffer)
+}
+
+// Set the connection state and notify the observer on change
+func (r *WSReconnectingClient) setState(state WSConnectionState, err error) {
+	r.mu.Lock()
+	if r.state == state || r.state == WSClosed {
+		r.mu.Unlock()
+		return
+	}
+	r.state = state
+	r.mu.Unlock()
+
+	if r.cfg.OnStateChanged != nil {
+		r.cfg.OnStateChanged(r, state, err)
+	}
+}
+
+// endregion
@@ -1,12 +1,17 @@
 package web
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"net/http"
+	"sync"
+	"time"
 )
 
 const (
-	WsPingOpCode = 0
+	WsPingOpCode  = 0
+	WsErrorOpCode = -1
 )
 
 // IWSMessage is a Web socket message header interface:
@@ -56,6 +61,27 @@ var pingMessage = NewWsPingMessage()
 
 // endregion
 
+// region Web Socket Error message ------------------------------------------------------------------------------------
+
+// WSErrorMessage message is sent back to the peer when a message handler fails
+type WSErrorMessage struct {
+	WSMessageHeader
+	Error string
+}
+
+// Payload returns the error description
+func (m *WSErrorMessage) Payload() any { return m.Error }
+
+// NewWsErrorMessage creates error message for the failed message (with its message ID and session ID)
+func NewWsErrorMessage(failed IWSMessage, err error) IWSMessage {
+	return &WSErrorMessage{
+		WSMessageHeader: WSMessageHeader{OpCode: WsErrorOpCode, MessageId: failed.MessageID(), SessionId: failed.SessionID()},
+		Error:           err.Error(),
+	}
+}
+
+// endregion
+
 // region Web Socket Raw message ---------------------------------------------------------------------------------------
 
 // WSRawMessage is a raw message structure
@@ -90,19 +116,67 @@ type MessageReceivedCb func(IWSClient, IWSMessage, int)
 // DisconnectedCb called when client disconnected
 type DisconnectedCb func(IWSClient)
 
+// WSKeepAlive is the ping/pong keepalive configuration of a web socket client
+type WSKeepAlive struct {
+	PingInterval time.Duration  // Interval of the ping control frames (0 to disable the keepalive)
+	PongTimeout  time.Duration  // Max time without pong or message before the peer is gone, above PingInterval (default: 2 x PingInterval)
+	OnPong       PongReceivedCb // Called on every pong with the ping round trip latency
+}
+
+// WSQueuePolicy defines the slow consumer policy when the outbound queue is full
+type WSQueuePolicy int
+
+const (
+	WSDropOldest WSQueuePolicy = iota // Drop the oldest queued message to make room for the new one
+	WSDropNewest                      // Drop the new message (Send returns error)
+	WSDisconnect                      // Disconnect the slow client (Send returns error)
+)
+
+// WSSendQueue is the send queue configuration of a web socket client
+type WSSendQueue struct {
+	Size   int           // Max messages waiting to be written (default: 256)
+	Policy WSQueuePolicy // Policy when the queue is full (default: drop oldest)
+}
+
+// Get the queue size
+func (q WSSendQueue) size() int {
+	if q.Size > 0 {
+		return q.Size
+	}
+	return wsDefaultSendQueueSize
+}
+
+// Get the read deadline timeout (0 when the keepalive is disabled)
+func (ka WSKeepAlive) timeout() time.Duration {
+	if ka.PingInterval <= 0 {
+		return 0
+	}
+	if ka.PongTimeout > ka.PingInterval {
+		return ka.PongTimeout
+	}
+	return 2 * ka.PingInterval
+}
+
 // endregion
 
 // region Web Socket client --------------------------------------------------------------------------------------------
 
 // WSConnectParams is the configuration for a web socket connection
 type WSConnectParams struct {
-	Url                string      // Full url (int is case path and host are ignored)
-	Path               string      // URL path segment
-	Host               string      // url host + port
-	WriteBufferSize    int         // Write buffer size (if not provided use the default 8K buffer)
-	ReadBufferSize     int         // Read buffer size (if not provided use the default 8K buffer)
-	CompressionEnabled bool        // Try to enable compression
-	Header             http.Header // List of HTTP headers
+	Url                string           // Full url (in this case path and host are ignored)
+	Path               string           // URL path segment
+	Host               string           // url host + port
+	WriteBufferSize    int              // Write buffer size (if not provided use the default 8K buffer)
+	ReadBufferSize     int              // Read buffer size (if not provided use the default 8K buffer)
+	CompressionEnabled bool             // Try to enable compression
+	Header             http.Header      // List of HTTP headers
+	TLSConfig          *tls.Config      // TLS configuration for wss:// connections (if not provided use the default)
+	Decoder            IMessageDecoder  // Message decoder (if not provided use the default JSON decoder)
+	OnDisconnect       DisconnectedCb   // Client disconnect callback
+	KeepAlive          WSKeepAlive      // Ping/pong keepalive (disabled if not provided)
+	SendQueue          WSSendQueue      // Send queue size and slow consumer policy
+	Fallback           WSMessageHandler // Handler of messages without entry for their op-code (ignored if not provided)
+	Dispatch           WSDispatchMode   // Concurrent (default) or sequential dispatch
 }
 
 // IWSClient is a Web socket client interface
@@ -120,33 +194,52 @@ type IWSClient interface {
 // MessageFactoryFunc is a function that creates a new message instance
 type MessageFactoryFunc func() IWSMessage
 
-var messageFactories = map[int]MessageFactoryFunc{}
+var messageFactories = map[int]MessageFactoryFunc{
+	WsErrorOpCode: func() IWSMessage { return &WSErrorMessage{} },
+}
+var messageFactoriesMu sync.RWMutex
 
 // AddMessageFactory adds a message factory for a given opcode
 func AddMessageFactory(opcode int, f MessageFactoryFunc) {
+	messageFactoriesMu.Lock()
+	defer messageFactoriesMu.Unlock()
 	messageFactories[opcode] = f
 }
 
 // GetMessageFactoryFunc returns the message factory for a given opcode
 func GetMessageFactoryFunc(opcode int) MessageFactoryFunc {
+	messageFactoriesMu.RLock()
+	defer messageFactoriesMu.RUnlock()
 	return messageFactories[opcode]
 }
 
 // JsonDecoder is a JSON message decoder
-type JsonDecoder struct{}
+type JsonDecoder struct {
+	factories map[int]MessageFactoryFunc
+}
 
-// NewJsonDecoder creates a new JSON message decoder
+// NewJsonDecoder creates a new JSON message decoder (messages are created by the registered message factories)
 func NewJsonDecoder() IMessageDecoder {
 	return &JsonDecoder{}
 }
 
+// NewJsonDecoderWithFactories creates a new JSON message decoder with its own message factories by op-code, messages of
+// other op-codes are created by the registered message factories (see AddMessageFactory)
+func NewJsonDecoderWithFactories(factories map[int]MessageFactoryFunc) IMessageDecoder {
+	d := &JsonDecoder{factories: make(map[int]MessageFactoryFunc, len(factories))}
+	for opCode, f := range factories {
+		d.factories[opCode] = f
+	}
+	return d
+}
+
 // Encode encodes a message to JSON
 func (_ JsonDecoder) Encode(m IWSMessage) (result []byte, err error) {
 	return json.Marshal(m)
 }
 
 // Decode decodes a JSON message
-func (_ JsonDecoder) Decode(buffer []byte) (msg IWSMessage, err error) {
+func (d JsonDecoder) Decode(buffer []byte) (msg IWSMessage, err error) {
 
 	bm := &WSMessageHeader{}
 
@@ -154,7 +247,11 @@ func (_ JsonDecoder) Decode(buffer []byte) (msg IWSMessage, err error) {
 		return nil, err
 	}
 
-	if mf, ok := messageFactories[bm.MessageCode()]; ok {
+	mf := d.factories[bm.MessageCode()]
+	if mf == nil {
+		mf = GetMessageFactoryFunc(bm.MessageCode())
+	}
+	if mf != nil {
 		msg = mf()
 		if err = json.Unmarshal(buffer, msg); err != nil {
 			return nil, err
@@ -180,10 +277,18 @@ type WSMessageHandler func(m IWSMessage, rw IWSClient) error
 // WSEntry is a web socket entry configuration
 type WSEntry struct {
 	OpCode  int                // Message op-code
-	Message MessageFactoryFunc // Message factory function
-	Handler WSMessageHandler   // Message handler function
+	Message MessageFactoryFunc // Message factory function (used by the JSON decoder of the endpoint or client only)
+	Handler WSMessageHandler   // Message handler function, the returned error is sent back as WSErrorMessage
 }
 
+// WSDispatchMode defines how the received messages of a client are dispatched to their handlers
+type WSDispatchMode int
+
+const (
+	WSDispatchConcurrent WSDispatchMode = iota // Each message is handled in its own goroutine
+	WSDispatchSequential                       // Messages are handled one by one, in the order received
+)
+
 // IWSEndpointConfig ia a Web socket endpoint configuration interface
 type IWSEndpointConfig interface {
 	Group() string        // Web socket registry group
@@ -191,9 +296,20 @@ type IWSEndpointConfig interface {
 	WSEntries() []WSEntry // List of Web socket entries configuration
 }
 
+// IWSFallbackEndpoint is an optional interface of IWSEndpointConfig to handle messages without entry for their op-code
+type IWSFallbackEndpoint interface {
+	Fallback(m IWSMessage, rw IWSClient) error // Handle message of unknown op-code
+}
+
+// IWSDispatchEndpoint is an optional interface of IWSEndpointConfig to set the dispatch mode of the clients
+type IWSDispatchEndpoint interface {
+	DispatchMode() WSDispatchMode // Concurrent (default) or sequential dispatch
+}
+
 // IWSClientRegistry is aWeb socket client registry
 type IWSClientRegistry interface {
 	Start()
+	Stop(ctx context.Context) error
 	RegisterClient(c IWSClient)
 	UnregisterClient(c IWSClient)
 	ConnectedClients() int